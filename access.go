@@ -0,0 +1,110 @@
+package cproject
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// commonLogLine matches a web server access log line in the Common Log Format.
+	commonLogLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)`)
+	// combinedLogSuffix matches what the Combined Log Format adds to the end of a Common Log Format line, along with
+	// an optional latency.
+	combinedLogSuffix = regexp.MustCompile(`^ "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"(?: (\d+(?:\.\d+)?))?$`)
+
+	// accessLogUnescaper undoes the escaping of quotes and backslashes in the quoted fields of an access log line.
+	accessLogUnescaper = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+)
+
+// accessLogFields returns the fields of a Common Log Format line along with the rest of the line after them.
+func accessLogFields(line string) (Fields, string, bool) {
+	m := commonLogLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, "", false
+	}
+
+	status, _ := strconv.Atoi(m[6])
+	fields := Fields{
+		"client":  m[1],
+		"request": accessLogUnescaper.Replace(m[5]),
+		"status":  status,
+		"bytes":   0,
+	}
+	if m[7] != "-" {
+		bytes, err := strconv.Atoi(m[7])
+		if err != nil {
+			return nil, "", false
+		}
+		fields["bytes"] = bytes
+	}
+	if m[2] != "-" {
+		fields["ident"] = m[2]
+	}
+	if m[3] != "-" {
+		fields["user"] = m[3]
+	}
+	ts, err := time.Parse(clfLayout, m[4])
+	if err != nil {
+		return nil, "", false
+	}
+	fields["timestamp"] = ts.Format(time.RFC3339)
+	// the request line is usually `METHOD PATH PROTOCOL`, but it's whatever the client sent.
+	if parts := strings.Fields(fields["request"].(string)); len(parts) >= 2 && len(parts) <= 3 {
+		fields["method"] = parts[0]
+		fields["path"] = parts[1]
+		if len(parts) == 3 {
+			fields["protocol"] = parts[2]
+		}
+	}
+	return fields, line[len(m[0]):], true
+}
+
+// CommonLogParser parses web server access log lines in the Common Log Format:
+// `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326`. The fields are `client`,
+// `ident`, `user`, `timestamp` (RFC3339), `request`, `method`, `path`, `protocol`, `status` and `bytes`; fields logged
+// as `-` are left out.
+type CommonLogParser struct{}
+
+// Parse parses the fields of a line. If the line isn't in the Common Log Format, false is returned.
+func (CommonLogParser) Parse(line string) (Fields, bool) {
+	fields, rest, ok := accessLogFields(line)
+	if !ok || rest != "" {
+		return nil, false
+	}
+	return fields, true
+}
+
+// CombinedLogParser parses web server access log lines in the Combined Log Format (the default of nginx and a
+// common Apache configuration): a Common Log Format line followed by the quoted referer and user agent. The fields
+// are those of CommonLogParser plus `referer` and `user_agent`. A number logged after the user agent (e.g. nginx's
+// `$request_time`) is the `latency` field.
+type CombinedLogParser struct{}
+
+// Parse parses the fields of a line. If the line isn't in the Combined Log Format, false is returned.
+func (CombinedLogParser) Parse(line string) (Fields, bool) {
+	fields, rest, ok := accessLogFields(line)
+	if !ok {
+		return nil, false
+	}
+	m := combinedLogSuffix.FindStringSubmatch(rest)
+	if m == nil {
+		return nil, false
+	}
+
+	if m[1] != "-" {
+		fields["referer"] = accessLogUnescaper.Replace(m[1])
+	}
+	if m[2] != "-" {
+		fields["user_agent"] = accessLogUnescaper.Replace(m[2])
+	}
+	if m[3] != "" {
+		latency, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, false
+		}
+		fields["latency"] = latency
+	}
+	return fields, true
+}
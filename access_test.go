@@ -0,0 +1,108 @@
+package cproject_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestAccessLogParsers(t *testing.T) {
+	fxtCommon := cproject.Fields{
+		"client": "127.0.0.1", "user": "frank", "timestamp": "2024-02-20T07:10:42-07:00",
+		"request": "GET /checkout HTTP/1.1", "method": "GET", "path": "/checkout", "protocol": "HTTP/1.1",
+		"status": 503, "bytes": 2326,
+	}
+	fxtCombined := cproject.Fields{"referer": "https://zoo.example/cart", "user_agent": `Mozilla/5.0 "zoo"`}
+	for k, v := range fxtCommon {
+		fxtCombined[k] = v
+	}
+	fxtLatency := cproject.Fields{"latency": 0.125}
+	for k, v := range fxtCombined {
+		fxtLatency[k] = v
+	}
+
+	testCases := []struct {
+		desc   string
+		parser cproject.LineParser
+		line   string
+		want   cproject.Fields
+	}{
+		{
+			desc:   "common",
+			parser: cproject.CommonLogParser{},
+			line:   `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326`,
+			want:   fxtCommon,
+		}, {
+			desc:   "commonNoBytes",
+			parser: cproject.CommonLogParser{},
+			line:   `127.0.0.1 - - [20/Feb/2024:07:10:42 -0700] "-" 400 -`,
+			want: cproject.Fields{
+				"client": "127.0.0.1", "timestamp": "2024-02-20T07:10:42-07:00", "request": "-", "status": 400,
+				"bytes": 0,
+			},
+		}, {
+			desc:   "commonRejectsCombined",
+			parser: cproject.CommonLogParser{},
+			line: `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326 ` +
+				`"https://zoo.example/cart" "Mozilla/5.0 \"zoo\""`,
+		}, {
+			desc:   "combined",
+			parser: cproject.CombinedLogParser{},
+			line: `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326 ` +
+				`"https://zoo.example/cart" "Mozilla/5.0 \"zoo\""`,
+			want: fxtCombined,
+		}, {
+			desc:   "combinedLatency",
+			parser: cproject.CombinedLogParser{},
+			line: `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326 ` +
+				`"https://zoo.example/cart" "Mozilla/5.0 \"zoo\"" 0.125`,
+			want: fxtLatency,
+		}, {
+			desc:   "combinedRejectsCommon",
+			parser: cproject.CombinedLogParser{},
+			line:   `127.0.0.1 - frank [20/Feb/2024:07:10:42 -0700] "GET /checkout HTTP/1.1" 503 2326`,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := tC.parser.Parse(tC.line)
+			if ok != (tC.want != nil) {
+				t.Fatalf("unexpected parse result - want: %t, got: %t (%#v)", tC.want != nil, ok, got)
+			}
+			if ok && !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("unexpected fields - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestAccessLogMatchField(t *testing.T) {
+	fxtLines := []string{
+		`10.0.0.1 - - [20/Feb/2024:07:10:40 -0700] "GET /checkout HTTP/1.1" 200 512 "-" "curl/8.0"`,
+		`10.0.0.2 - - [20/Feb/2024:07:10:41 -0700] "POST /checkout HTTP/1.1" 502 0 "-" "curl/8.0"`,
+		`10.0.0.3 - - [20/Feb/2024:07:10:42 -0700] "GET /cart HTTP/1.1" 500 0 "-" "curl/8.0"`,
+	}
+	parser := cproject.CombinedLogParser{}
+	status, err := cproject.NewMatchField(parser,
+		cproject.FieldPredicate{Field: "status", Op: cproject.OpGe, Value: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := cproject.NewMatchField(parser,
+		cproject.FieldPredicate{Field: "path", Op: cproject.OpEq, Value: "/checkout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := cproject.All{status, path}
+	var got []string
+	for _, line := range fxtLines {
+		if filter.Include(line) {
+			got = append(got, line)
+		}
+	}
+	if want := fxtLines[1:2]; !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+}
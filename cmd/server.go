@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/marklap/cproject/handlers"
 )
@@ -35,6 +36,7 @@ var (
 	listenPort       int
 	pathPrefixesList string
 	pathPrefixes     []string
+	maxFollow        time.Duration
 )
 
 var logger = log.Default()
@@ -46,6 +48,8 @@ func init() {
 	flag.IntVar(&listenPort, "port", DefaultListenPort, "port to listen on")
 	flag.StringVar(&pathPrefixesList, "prefixes", DefaultPathPrefixes,
 		fmt.Sprintf("path prefixes to use for path validation [%q deliminted]", os.PathListSeparator))
+	flag.DurationVar(&maxFollow, "max-follow", handlers.DefaultMaxFollow,
+		"maximum duration a followed file is streamed")
 	flag.Parse()
 
 	if pathPrefixesList == "" {
@@ -58,7 +62,12 @@ func main() {
 	mux := http.NewServeMux()
 
 	mux.Handle("/ping", handlers.PingHandler(logger))
-	mux.Handle("/tail", handlers.TailHandler(logger, fmt.Sprintf("%s:%d", hostname, listenPort), pathPrefixes))
+	mux.Handle("/tail", handlers.TailHandler(logger, fmt.Sprintf("%s:%d", hostname, listenPort), pathPrefixes,
+		maxFollow))
+	mux.Handle("/tail/stream", handlers.StreamHandler(logger, fmt.Sprintf("%s:%d", hostname, listenPort),
+		pathPrefixes, maxFollow))
+	mux.Handle("/tail/ws", handlers.WebSocketHandler(logger, fmt.Sprintf("%s:%d", hostname, listenPort),
+		pathPrefixes, maxFollow))
 
 	listenAddr := fmt.Sprintf("%s:%d", listenIP, listenPort)
 	logger.Printf("%s API server is listening on %s...", ProjectName, listenAddr)
@@ -0,0 +1,349 @@
+package cproject
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Compression is the compression format of a log file.
+type Compression int
+
+const (
+	// CompressionNone is an uncompressed log file.
+	CompressionNone Compression = iota
+	// CompressionGzip is a log file compressed with gzip (e.g. `syslog.2.gz`).
+	CompressionGzip
+	// CompressionBzip2 is a log file compressed with bzip2 (e.g. `app.log.3.bz2`).
+	CompressionBzip2
+)
+
+var (
+	// gzipMagic are the bytes a gzip stream starts with.
+	gzipMagic = []byte{0x1f, 0x8b}
+	// bzip2Magic are the bytes a bzip2 stream starts with.
+	bzip2Magic = []byte("BZh")
+)
+
+// String returns the name of the compression format.
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionBzip2:
+		return "bzip2"
+	}
+	return "none"
+}
+
+// DetectCompression determines the compression format of a file by the magic bytes at the start of it.
+func DetectCompression(file io.ReaderAt) (Compression, error) {
+	buf := make([]byte, len(bzip2Magic))
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return CompressionNone, err
+	}
+
+	switch {
+	case bytes.HasPrefix(buf[:n], gzipMagic):
+		return CompressionGzip, nil
+	case bytes.HasPrefix(buf[:n], bzip2Magic):
+		return CompressionBzip2, nil
+	}
+	return CompressionNone, nil
+}
+
+// lineRing keeps the last `size` lines added to it. A size of 0 or less keeps every line.
+type lineRing struct {
+	size  int
+	lines []Line
+	// next is the index the next line is written to once the ring is full.
+	next int
+}
+
+// add adds a line to the ring, dropping the oldest line if the ring is full.
+func (r *lineRing) add(line Line) {
+	if r.size <= 0 || len(r.lines) < r.size {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.size
+}
+
+// ordered returns the lines in the ring oldest to newest.
+func (r *lineRing) ordered() []Line {
+	ordered := make([]Line, 0, len(r.lines))
+	ordered = append(ordered, r.lines[r.next:]...)
+	return append(ordered, r.lines[:r.next]...)
+}
+
+// CompressedLogFile reads log files compressed with gzip or bzip2, such as rotated logs. Compressed streams can't be
+// read backwards, so tailing a compressed log file streams the whole file forward keeping only the last `n` lines
+// that pass the filters. Likewise, a time range can't be searched for, so lines outside of it are skipped as the file
+// is streamed, and the context of the lines that pass the filters is kept as they are. Line offsets are offsets into
+// the decompressed content and, since every line is read, lines are always numbered when line numbers are asked for.
+type CompressedLogFile struct {
+	logFile     *LogFile
+	compression Compression
+}
+
+// NewCompressedLogFile creates a new CompressedLogFile and applies the provided options. An error is returned if the
+// file isn't compressed in a supported format.
+func NewCompressedLogFile(path string, opts ...logFileOpt) (*CompressedLogFile, error) {
+	lf, err := OpenLogFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cf, ok := lf.(*CompressedLogFile)
+	if !ok {
+		lf.Close()
+		return nil, fmt.Errorf("file is not compressed: %s", path)
+	}
+	return cf, nil
+}
+
+// OpenLogFile opens the log file at `path` with the provided options. Compressed log files are detected by their
+// magic bytes and returned as a CompressedLogFile; anything else is returned as a LogFile.
+func OpenLogFile(path string, opts ...logFileOpt) (LogFileReader, error) {
+	lf, err := NewLogFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	compression, err := DetectCompression(lf.file)
+	if err != nil {
+		lf.Close()
+		return nil, fmt.Errorf("error detecting compression: %w", err)
+	}
+	if compression == CompressionNone {
+		return lf, nil
+	}
+
+	return &CompressedLogFile{
+		logFile:     lf,
+		compression: compression,
+	}, nil
+}
+
+// Path is the path to the log file being read.
+func (f *CompressedLogFile) Path() string {
+	return f.logFile.Path()
+}
+
+// ID identifies the compressed file being read independent of its path.
+func (f *CompressedLogFile) ID() (FileID, error) {
+	return f.logFile.ID()
+}
+
+// Compression is the compression format of the log file.
+func (f *CompressedLogFile) Compression() Compression {
+	return f.compression
+}
+
+// decompressor returns a reader of the decompressed content of the log file.
+func (f *CompressedLogFile) decompressor() (io.Reader, error) {
+	stat, err := f.logFile.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	compressed := io.NewSectionReader(f.logFile.file, 0, stat.Size())
+
+	if f.compression == CompressionBzip2 {
+		return bzip2.NewReader(compressed), nil
+	}
+	return gzip.NewReader(compressed)
+}
+
+// timestamp parses the timestamp of a line if the lines read are limited to a time range.
+func (f *CompressedLogFile) timestamp(line string) (time.Time, bool) {
+	if !f.logFile.hasTimeRange() {
+		return time.Time{}, false
+	}
+	return ParseTimestamp(line)
+}
+
+// readLines streams the decompressed log file and passes the lines in its time range, and in `rng` if it isn't nil,
+// to `fn` in the order they appear. Lines are grouped into records if the log file has a record start. Reading stops
+// when `fn` returns false. If the context is done before reading finishes, the context's error is returned.
+func (f *CompressedLogFile) readLines(ctx context.Context, rng *Range, fn func(Line) bool) error {
+	r, err := f.decompressor()
+	if err != nil {
+		return err
+	}
+
+	// lines are grouped into records before they are passed along when the log file has a record start.
+	var records *forwardRecords
+	if f.logFile.recordStart != nil {
+		records = &forwardRecords{start: f.logFile.recordStart}
+	}
+
+	reader := bufio.NewReaderSize(r, int(stdBufSize))
+	offset, number := int64(0), int64(0)
+	// inRange tracks whether the lines being read are in the time range; lines without a timestamp go with the line
+	// before them, so lines before the first timestamp are only in range if there isn't a time range.
+	inRange := !f.logFile.hasTimeRange()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.logFile.end >= 0 && offset >= f.logFile.end {
+			break
+		}
+
+		text, err := reader.ReadString(newline)
+		if len(text) > 0 {
+			line := Line{Text: strings.TrimSuffix(text, string(newline)), Offset: offset}
+			offset += int64(len(text))
+			number++
+			if f.logFile.lineNumbers {
+				line.Number = number
+			}
+			if ts, ok := f.timestamp(line.Text); ok {
+				if !f.logFile.until.IsZero() && ts.After(f.logFile.until) {
+					// lines are in timestamp order, so the rest of the file is out of range too.
+					break
+				}
+				inRange = inTimeRange(ts, f.logFile.since, f.logFile.until)
+			}
+			inSpan := true
+			if rng != nil {
+				var past bool
+				if inSpan, past = rng.contains(line.Offset, number); past {
+					break
+				}
+			}
+			if inRange && inSpan && line.Text != "" {
+				if records == nil {
+					if !fn(line) {
+						return ctx.Err()
+					}
+				} else if record, ok := records.add(line); ok && !fn(record) {
+					return ctx.Err()
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if records != nil {
+		if record, ok := records.flush(); ok {
+			fn(record)
+		}
+	}
+	return ctx.Err()
+}
+
+// tailLines reads up to `numLines` lines (or records) from the end of the decompressed log file and passes them to
+// `fn` in the configured order. Reading stops when `fn` returns false. If the context is done before reading
+// finishes, the context's error is returned.
+func (f *CompressedLogFile) tailLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	ring := &lineRing{size: numLines}
+	keep := func(line Line) bool {
+		if passesFilters(line.Text, filters) {
+			ring.add(line)
+		}
+		return true
+	}
+	// every line is kept while the lines around the ones that pass the filters are read too.
+	var around *forwardContext
+	if f.logFile.hasContext() {
+		around = newForwardContext(filters, f.logFile.before, f.logFile.after, numLines)
+		keep = func(line Line) bool {
+			around.add(line)
+			return true
+		}
+	}
+	if err := f.readLines(ctx, nil, keep); err != nil {
+		return err
+	}
+
+	lines := ring.ordered()
+	if around != nil {
+		lines = around.gathered()
+	}
+	if f.logFile.order == OrderForward {
+		for _, line := range lines {
+			if !fn(line) {
+				break
+			}
+		}
+	} else {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if !fn(lines[i]) {
+				break
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// headLines reads up to `numLines` lines (or records) that pass the filters from the start of the decompressed log
+// file, or of `rng` if it isn't nil, and passes them to `fn` in the order they appear. Reading stops when `fn` returns
+// false. If the context is done before reading finishes, the context's error is returned.
+func (f *CompressedLogFile) headLines(ctx context.Context, rng *Range, numLines int, filters []Filter,
+	fn func(Line) bool) error {
+	count := 0
+	return f.readLines(ctx, rng, func(line Line) bool {
+		if !passesFilters(line.Text, filters) {
+			return true
+		}
+		count++
+		return fn(line) && (numLines <= 0 || count < numLines)
+	})
+}
+
+// YieldLines returns a string channel and an error channel for streaming lines from a log file.
+func (f *CompressedLogFile) YieldLines(numLines int, filters ...Filter) (chan string, chan error) {
+	return streamText(func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// YieldLinesContext returns a line channel and an error channel for streaming lines from a log file. Streaming stops,
+// and the context's error is sent on the error channel, when the context is done.
+func (f *CompressedLogFile) YieldLinesContext(ctx context.Context, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// HeadLines returns a line channel and an error channel for streaming the first `numLines` lines of a log file that
+// pass the filters, in the order they appear in the file. Streaming stops, and the context's error is sent on the
+// error channel, when the context is done.
+func (f *CompressedLogFile) HeadLines(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.headLines(ctx, nil, numLines, filters, fn)
+	})
+}
+
+// RangeLines returns a line channel and an error channel for streaming up to `numLines` lines of a range of the
+// decompressed log file (see Range) that pass the filters, in the order they appear in the file. Streaming stops,
+// and the context's error is sent on the error channel, when the context is done.
+func (f *CompressedLogFile) RangeLines(ctx context.Context, rng Range, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		if err := rng.Validate(); err != nil {
+			return err
+		}
+		return f.headLines(ctx, &rng, numLines, filters, fn)
+	})
+}
+
+// Close closes the log file handle.
+func (f *CompressedLogFile) Close() error {
+	return f.logFile.Close()
+}
@@ -0,0 +1,143 @@
+package cproject_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestDetectCompression(t *testing.T) {
+	testCases := []struct {
+		desc string
+		path string
+		want cproject.Compression
+	}{
+		{
+			desc: "none",
+			path: "./testdata/number_lines.txt",
+			want: cproject.CompressionNone,
+		}, {
+			desc: "gzip",
+			path: "./testdata/number_lines.txt.gz",
+			want: cproject.CompressionGzip,
+		}, {
+			desc: "bzip2",
+			path: "./testdata/number_lines.txt.bz2",
+			want: cproject.CompressionBzip2,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			file, err := os.Open(tC.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			got, err := cproject.DetectCompression(file)
+			if err != nil {
+				t.Error(err)
+			}
+			if tC.want != got {
+				t.Errorf("unexpected compression - want: %s, got: %s", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenLogFile(t *testing.T) {
+	plain, err := cproject.OpenLogFile("./testdata/number_lines.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, ok := plain.(*cproject.LogFile); !ok {
+		t.Errorf("unexpected reader for uncompressed file - want: *cproject.LogFile, got: %T", plain)
+	}
+
+	compressed, err := cproject.OpenLogFile("./testdata/number_lines.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compressed.Close()
+	if _, ok := compressed.(*cproject.CompressedLogFile); !ok {
+		t.Errorf("unexpected reader for compressed file - want: *cproject.CompressedLogFile, got: %T", compressed)
+	}
+
+	if _, err := cproject.NewCompressedLogFile("./testdata/number_lines.txt"); err == nil {
+		t.Errorf("no error returned - expected error for uncompressed file")
+	}
+}
+
+func TestCompressedLogFileYieldLines(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		path    string
+		lines   int
+		order   cproject.Order
+		filters []cproject.Filter
+		want    []string
+	}{
+		{
+			desc:  "gzipLastTwo",
+			path:  "./testdata/number_lines.txt.gz",
+			lines: 2,
+			want: []string{
+				"1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000",
+				"0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999",
+			},
+		}, {
+			desc:  "bzip2LastTwo",
+			path:  "./testdata/number_lines.txt.bz2",
+			lines: 2,
+			want: []string{
+				"1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000",
+				"0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999",
+			},
+		}, {
+			desc:  "gzipLastTwoForward",
+			path:  "./testdata/number_lines.txt.gz",
+			lines: 2,
+			order: cproject.OrderForward,
+			want: []string{
+				"0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999 0999",
+				"1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000 1000",
+			},
+		}, {
+			desc:  "bzip2Filtered",
+			path:  "./testdata/number_lines.txt.bz2",
+			lines: 10,
+			filters: []cproject.Filter{
+				cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"0002 ", "0001 "})),
+			},
+			want: []string{
+				"0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002 0002",
+				"0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001 0001",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			logFile, err := cproject.NewCompressedLogFile(tC.path, cproject.WithOrder(tC.order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logFile.Close()
+
+			lines, errChan := logFile.YieldLines(tC.lines, tC.filters...)
+
+			var got []string
+			for line := range lines {
+				got = append(got, line)
+			}
+			if err := <-errChan; err != nil {
+				t.Error(err)
+			}
+
+			if !cproject.StringSlicesEqual(tC.want, got) {
+				t.Errorf("unexpected results - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
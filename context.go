@@ -0,0 +1,203 @@
+package cproject
+
+// contextLine is a line along with its position in the stream of lines it was read from.
+type contextLine struct {
+	Line
+	index int
+}
+
+// separator is the marker between groups of lines that aren't next to each other in the log file.
+var separator = Line{Kind: LineSeparator}
+
+// reverseContext passes along the lines that pass the filters, as LineText, along with up to `before` lines before
+// and `after` lines after each of them in the log file, as LineContext (like `grep -B -A`). Lines are taken newest
+// first and passed along in the same order, with a LineSeparator between groups of lines that aren't next to each
+// other. Up to `numLines` lines that pass the filters are passed along; if `numLines` is 0 or less, there is no limit.
+type reverseContext struct {
+	filters  []Filter
+	before   int
+	after    int
+	numLines int
+	fn       func(Line) bool
+
+	// pending are the last lines read that weren't passed along; they are the lines after the next match.
+	pending []contextLine
+	// index is the position of the next line read.
+	index int
+	// lastIndex is the position of the last line passed along, or -1 if none have been.
+	lastIndex int
+	// beforeLeft is how many more lines before the last match are passed along.
+	beforeLeft int
+	matches    int
+}
+
+// newReverseContext creates a new reverseContext that passes lines to `fn`.
+func newReverseContext(filters []Filter, before, after, numLines int, fn func(Line) bool) *reverseContext {
+	return &reverseContext{
+		filters:   filters,
+		before:    before,
+		after:     after,
+		numLines:  numLines,
+		fn:        fn,
+		lastIndex: -1,
+	}
+}
+
+// emit passes a line along, after a separator if it isn't next to the last line passed along. It returns false if
+// `fn` did.
+func (c *reverseContext) emit(line contextLine) bool {
+	if c.lastIndex >= 0 && line.index != c.lastIndex+1 && !c.fn(separator) {
+		return false
+	}
+	c.lastIndex = line.index
+	return c.fn(line.Line)
+}
+
+// done returns true if all of the lines that will be passed along have been.
+func (c *reverseContext) done() bool {
+	return c.numLines > 0 && c.matches >= c.numLines && c.beforeLeft == 0
+}
+
+// add takes the next line. It returns false when no more lines are needed.
+func (c *reverseContext) add(line Line) bool {
+	current := contextLine{Line: line, index: c.index}
+	c.index++
+
+	// every line taken is checked against the filters, even once enough lines have passed them, so filters see all the
+	// lines that are read.
+	matched := passesFilters(line.Text, c.filters)
+	if matched && (c.numLines <= 0 || c.matches < c.numLines) {
+		for _, p := range c.pending {
+			p.Kind = LineContext
+			if !c.emit(p) {
+				return false
+			}
+		}
+		c.pending = c.pending[:0]
+		c.matches++
+		c.beforeLeft = c.before
+		return c.emit(current) && !c.done()
+	}
+
+	current.Kind = LineContext
+	if c.beforeLeft > 0 {
+		c.beforeLeft--
+		return c.emit(current) && !c.done()
+	}
+	if c.after > 0 {
+		if len(c.pending) == c.after {
+			c.pending = append(c.pending[:0], c.pending[1:]...)
+		}
+		c.pending = append(c.pending, current)
+	}
+	return true
+}
+
+// forwardContext gathers the lines that pass the filters, as LineText, along with up to `before` lines before and
+// `after` lines after each of them in the log file, as LineContext (like `grep -B -A`). Lines are taken in the order
+// they appear in the log file and a LineSeparator is put between groups of lines that aren't next to each other.
+// Only the last `numLines` lines that pass the filters, and their context, are kept; if `numLines` is 0 or less,
+// they all are.
+type forwardContext struct {
+	filters  []Filter
+	before   int
+	after    int
+	numLines int
+
+	// lines are the lines gathered so far.
+	lines []Line
+	// groups are where each kept match, and the context before it, are in lines.
+	groups []contextGroup
+	// pending are the last lines read that weren't gathered; they are the lines before the next match.
+	pending []contextLine
+	// index is the position of the next line read.
+	index int
+	// lastIndex is the position of the last line gathered, or -1 if none have been.
+	lastIndex int
+	// afterLeft is how many more lines after the last match are gathered.
+	afterLeft int
+}
+
+// contextGroup is where a match, and the context before it, are in the lines gathered.
+type contextGroup struct {
+	start int
+	match int
+}
+
+// newForwardContext creates a new forwardContext.
+func newForwardContext(filters []Filter, before, after, numLines int) *forwardContext {
+	return &forwardContext{
+		filters:   filters,
+		before:    before,
+		after:     after,
+		numLines:  numLines,
+		lastIndex: -1,
+	}
+}
+
+// gather gathers a line, after a separator if it isn't next to the last line gathered.
+func (c *forwardContext) gather(line contextLine) {
+	if c.lastIndex >= 0 && line.index != c.lastIndex+1 {
+		c.lines = append(c.lines, separator)
+	}
+	c.lastIndex = line.index
+	c.lines = append(c.lines, line.Line)
+}
+
+// add takes the next line.
+func (c *forwardContext) add(line Line) {
+	current := contextLine{Line: line, index: c.index}
+	c.index++
+
+	if !passesFilters(line.Text, c.filters) {
+		current.Kind = LineContext
+		if c.afterLeft > 0 {
+			c.afterLeft--
+			c.gather(current)
+		} else if c.before > 0 {
+			if len(c.pending) == c.before {
+				c.pending = append(c.pending[:0], c.pending[1:]...)
+			}
+			c.pending = append(c.pending, current)
+		}
+		return
+	}
+
+	for _, p := range c.pending {
+		p.Kind = LineContext
+		c.gather(p)
+	}
+	c.pending = c.pending[:0]
+	c.gather(current)
+	c.afterLeft = c.after
+
+	// the match's context is the lines gathered right before it, whether they were gathered for this match or as the
+	// context after the one before it.
+	group := contextGroup{start: len(c.lines) - 1, match: len(c.lines) - 1}
+	for n := 0; n < c.before && group.start > 0 && c.lines[group.start-1].Kind != LineSeparator; n++ {
+		group.start--
+	}
+	c.groups = append(c.groups, group)
+
+	if c.numLines > 0 && len(c.groups) > c.numLines {
+		// drop the oldest match and everything before the context of the one after it.
+		cut := c.groups[1].start
+		c.lines = append(c.lines[:0], c.lines[cut:]...)
+		c.groups = c.groups[1:]
+		for i := range c.groups {
+			c.groups[i].start -= cut
+			c.groups[i].match -= cut
+		}
+		// a dropped match can still be in the context before the oldest kept one.
+		for i := 0; i < c.groups[0].match; i++ {
+			if c.lines[i].Kind == LineText {
+				c.lines[i].Kind = LineContext
+			}
+		}
+	}
+}
+
+// gathered returns the lines gathered, in the order they appear in the log file.
+func (c *forwardContext) gathered() []Line {
+	return c.lines
+}
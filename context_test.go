@@ -0,0 +1,124 @@
+package cproject_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+// fxtContextLines are the lines of the context fixture.
+var fxtContextLines = []string{
+	"2024-02-20T07:10:40Z INFO fed the monkey",
+	"2024-02-20T07:10:41Z INFO fed the octopus",
+	"2024-02-20T07:10:42Z ERROR out of bananas",
+	"2024-02-20T07:10:43Z INFO fed the zebra",
+	"2024-02-20T07:10:44Z INFO fed the lion",
+	"2024-02-20T07:10:45Z INFO fed the tiger",
+	"2024-02-20T07:10:46Z ERROR out of crabs",
+	"2024-02-20T07:10:47Z INFO fed the penguin",
+}
+
+// FxtContextFile creates a log file of the context fixture, along with a gzip compressed copy, and returns their
+// paths.
+func FxtContextFile(t *testing.T) (string, string) {
+	t.Helper()
+	return cproject.FxtPlainAndGzip(t, "zoo.log", strings.Join(fxtContextLines, "\n")+"\n")
+}
+
+func TestLogFileWithContext(t *testing.T) {
+	errorLines := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+	// line describes a line of the fixture as it's expected to be read.
+	line := func(kind cproject.LineKind, i int) string {
+		return kind.String() + " " + fxtContextLines[i]
+	}
+	match := func(i int) string { return line(cproject.LineText, i) }
+	around := func(i int) string { return line(cproject.LineContext, i) }
+	separator := cproject.LineSeparator.String() + " "
+
+	testCases := []struct {
+		desc   string
+		before int
+		after  int
+		lines  int
+		order  cproject.Order
+		want   []string
+	}{
+		{
+			desc:   "separated",
+			before: 1,
+			after:  1,
+			want:   []string{around(7), match(6), around(5), separator, around(3), match(2), around(1)},
+		}, {
+			desc:   "separatedForward",
+			before: 1,
+			after:  1,
+			order:  cproject.OrderForward,
+			want:   []string{around(1), match(2), around(3), separator, around(5), match(6), around(7)},
+		}, {
+			desc:   "adjacent",
+			before: 2,
+			after:  2,
+			order:  cproject.OrderForward,
+			want: []string{around(0), around(1), match(2), around(3), around(4), around(5), match(6),
+				around(7)},
+		}, {
+			desc:   "beforeOnly",
+			before: 1,
+			want:   []string{match(6), around(5), separator, match(2), around(1)},
+		}, {
+			desc:  "afterOnly",
+			after: 1,
+			order: cproject.OrderForward,
+			want:  []string{match(2), around(3), separator, match(6), around(7)},
+		}, {
+			desc:   "lastMatch",
+			before: 1,
+			after:  1,
+			lines:  1,
+			order:  cproject.OrderForward,
+			want:   []string{around(5), match(6), around(7)},
+		}, {
+			desc:   "droppedMatchInContext",
+			before: 4,
+			lines:  1,
+			want:   []string{match(6), around(5), around(4), around(3), around(2)},
+		}, {
+			desc:   "droppedMatchInContextForward",
+			before: 4,
+			lines:  1,
+			order:  cproject.OrderForward,
+			want:   []string{around(2), around(3), around(4), around(5), match(6)},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order),
+					cproject.WithContext(tC.before, tC.after))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				lines, errChan := logFile.YieldLinesContext(context.Background(), tC.lines, errorLines)
+
+				var got []string
+				for line := range lines {
+					got = append(got, line.Kind.String()+" "+line.Text)
+				}
+				if err := <-errChan; err != nil {
+					t.Error(err)
+				}
+
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
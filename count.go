@@ -0,0 +1,66 @@
+package cproject
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Counts summarizes the lines of a log file that pass the filters.
+type Counts struct {
+	// Matched is the number of lines (or records) that pass the filters.
+	Matched int64
+	// ScannedLines is the number of lines scanned, including the empty lines between them.
+	ScannedLines int64
+	// ScannedBytes is the number of bytes of the lines scanned, newlines included.
+	ScannedBytes int64
+	// First is the timestamp (see ParseTimestamp) of the first line that passes the filters and has one, or the zero
+	// time if none do.
+	First time.Time
+	// Last is the timestamp of the last line that passes the filters and has one, or the zero time if none do.
+	Last time.Time
+}
+
+// add counts a line (or record) read from the log file.
+func (c *Counts) add(line Line, filters []Filter) {
+	c.ScannedLines += int64(strings.Count(line.Text, string(newline))) + 1
+	c.ScannedBytes += int64(len(line.Text)) + 1
+	if !passesFilters(line.Text, filters) {
+		return
+	}
+	c.Matched++
+	if ts, ok := ParseTimestamp(line.Text); ok {
+		if c.First.IsZero() {
+			c.First = ts
+		}
+		c.Last = ts
+	}
+}
+
+// CountLines reads the lines of a log file, in the order they appear (see HeadLines), or of a range of it if `rng`
+// isn't nil (see RangeLines), and counts the lines that pass the filters. Lines are read the way the log file is
+// configured to read them (e.g. in a time range or grouped into records). Reading stops with the context's error when
+// the context is done.
+func CountLines(ctx context.Context, logFile LogFileReader, rng *Range, filters ...Filter) (Counts, error) {
+	var lines chan Line
+	var errChan chan error
+	if rng != nil {
+		lines, errChan = logFile.RangeLines(ctx, *rng, 0)
+	} else {
+		lines, errChan = logFile.HeadLines(ctx, 0)
+	}
+
+	counts := Counts{}
+	// end is the offset just past the last line counted, in the file it was read from.
+	end, source := int64(-1), ""
+	for line := range lines {
+		// empty lines aren't read as lines, so the bytes skipped between two lines of a file are empty lines.
+		if skipped := line.Offset - end; end >= 0 && line.Source == source && skipped > 0 {
+			counts.ScannedLines += skipped
+			counts.ScannedBytes += skipped
+		}
+		counts.add(line, filters)
+		end, source = line.Offset+int64(len(line.Text))+1, line.Source
+	}
+	return counts, <-errChan
+}
@@ -0,0 +1,126 @@
+package cproject_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+func TestCountLines(t *testing.T) {
+	errorLines := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+	size := int64(len(strings.Join(fxtContextLines, "\n")) + 1)
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	testCases := []struct {
+		desc    string
+		rng     *cproject.Range
+		filters []cproject.Filter
+		want    cproject.Counts
+	}{
+		{
+			desc:    "filtered",
+			filters: []cproject.Filter{errorLines},
+			want: cproject.Counts{
+				Matched:      2,
+				ScannedLines: 8,
+				ScannedBytes: size,
+				First:        at("2024-02-20T07:10:42Z"),
+				Last:         at("2024-02-20T07:10:46Z"),
+			},
+		}, {
+			desc: "unfiltered",
+			want: cproject.Counts{
+				Matched:      8,
+				ScannedLines: 8,
+				ScannedBytes: size,
+				First:        at("2024-02-20T07:10:40Z"),
+				Last:         at("2024-02-20T07:10:47Z"),
+			},
+		}, {
+			desc:    "range",
+			rng:     &cproject.Range{Unit: cproject.RangeLines, Start: 1, End: 3},
+			filters: []cproject.Filter{errorLines},
+			want: cproject.Counts{
+				ScannedLines: 2,
+				ScannedBytes: int64(len(fxtContextLines[0]) + len(fxtContextLines[1]) + 2),
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				got, err := cproject.CountLines(context.Background(), logFile, tC.rng, tC.filters...)
+				if err != nil {
+					t.Error(err)
+				}
+				if tC.want != got {
+					t.Errorf("unexpected counts for %s - want: %+v, got: %+v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCountLinesEmptyLines(t *testing.T) {
+	content := "fed the monkey\n\nfed the octopus\n\n\nfed the zebra\n"
+	plain, compressed := cproject.FxtPlainAndGzip(t, "zoo.log", content)
+
+	for _, path := range []string{plain, compressed} {
+		logFile, err := cproject.OpenLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logFile.Close()
+
+		got, err := cproject.CountLines(context.Background(), logFile, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if got.ScannedLines != 6 || got.ScannedBytes != int64(len(content)) {
+			t.Errorf("unexpected counts for %s - want: 6 lines of %d bytes, got: %d lines of %d bytes",
+				filepath.Base(path), len(content), got.ScannedLines, got.ScannedBytes)
+		}
+	}
+}
+
+func TestCountLinesRecords(t *testing.T) {
+	timestampStart, err := cproject.NewMatchRegexp(`^\d{4}-\d{2}-\d{2}T`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := FxtRecordsFile(t)
+
+	logFile, err := cproject.OpenLogFile(plain, cproject.WithRecordStart(timestampStart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	failed := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"failed"}))
+	got, err := cproject.CountLines(context.Background(), logFile, nil, failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Matched != 2 || got.ScannedLines != 11 {
+		t.Errorf("unexpected counts - want: 2 matched of 11 lines, got: %d matched of %d lines", got.Matched,
+			got.ScannedLines)
+	}
+}
@@ -0,0 +1,276 @@
+package cproject
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Fields are the structured fields parsed from a log line. Nested objects are kept as nested maps.
+type Fields map[string]interface{}
+
+// Lookup returns the value of the field at `path`. A path may name a nested field by joining the names of the
+// fields leading to it with dots (e.g. `user.id`); a field with a dot in its name is found before a nested field.
+func (f Fields) Lookup(path string) (interface{}, bool) {
+	if value, ok := f[path]; ok {
+		return value, true
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		switch nested := f[path[:i]].(type) {
+		case map[string]interface{}:
+			if value, ok := Fields(nested).Lookup(path[i+1:]); ok {
+				return value, true
+			}
+		case Fields:
+			if value, ok := nested.Lookup(path[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Project returns the fields at the provided paths keyed by path. Paths that aren't found are left out.
+func (f Fields) Project(paths []string) Fields {
+	projected := Fields{}
+	for _, path := range paths {
+		if value, ok := f.Lookup(path); ok {
+			projected[path] = value
+		}
+	}
+	return projected
+}
+
+// LineParser describes the behavior of a parser of structured log lines.
+type LineParser interface {
+	// Parse parses the fields of a line. If the line isn't in the parser's format, false is returned.
+	Parse(string) (Fields, bool)
+}
+
+// ParseFormat returns the parser of the structured line format named by the provided string: `json`, `logfmt`,
+// `syslog` (RFC 3164), `rfc5424`, `common` or `combined`. An empty string is the default format (json).
+func ParseFormat(s string) (LineParser, error) {
+	switch s {
+	case "", "json":
+		return JSONParser{}, nil
+	case "logfmt":
+		return LogfmtParser{}, nil
+	case "syslog":
+		return SyslogParser{}, nil
+	case "rfc5424":
+		return RFC5424Parser{}, nil
+	case "common":
+		return CommonLogParser{}, nil
+	case "combined":
+		return CombinedLogParser{}, nil
+	}
+	return nil, fmt.Errorf("unknown format: %q", s)
+}
+
+// JSONParser parses lines that are a JSON object (one object per line). Numbers are kept as json.Number so they
+// aren't rounded.
+type JSONParser struct{}
+
+// Parse parses the fields of a line. If the line isn't a JSON object, false is returned.
+func (JSONParser) Parse(line string) (Fields, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var fields Fields
+	if err := dec.Decode(&fields); err != nil {
+		return nil, false
+	}
+	// anything after the object means the line isn't just a JSON object.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return fields, true
+}
+
+// FieldOp is a comparison of a field with a value.
+type FieldOp string
+
+const (
+	// OpEq matches fields equal to the value.
+	OpEq FieldOp = "=="
+	// OpNe matches fields not equal to the value.
+	OpNe FieldOp = "!="
+	// OpLt matches fields less than the value.
+	OpLt FieldOp = "<"
+	// OpLe matches fields less than or equal to the value.
+	OpLe FieldOp = "<="
+	// OpGt matches fields greater than the value.
+	OpGt FieldOp = ">"
+	// OpGe matches fields greater than or equal to the value.
+	OpGe FieldOp = ">="
+	// OpIn matches fields equal to one of the values in a list.
+	OpIn FieldOp = "in"
+	// OpExists matches lines that have the field, whatever its value.
+	OpExists FieldOp = "exists"
+)
+
+// ParseFieldOp returns the FieldOp named by the provided string.
+func ParseFieldOp(s string) (FieldOp, error) {
+	switch op := FieldOp(s); op {
+	case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe, OpIn, OpExists:
+		return op, nil
+	}
+	return "", fmt.Errorf("unknown field operator: %q", s)
+}
+
+// FieldPredicate is a comparison of the field at a path (see Fields.Lookup) with a value. Values that are numbers,
+// or strings that hold numbers, are compared as numbers; anything else is compared as a string. The value of OpIn is
+// a list of values and OpExists has no value.
+type FieldPredicate struct {
+	Field string
+	Op    FieldOp
+	Value interface{}
+}
+
+// Match returns true if the fields satisfy the predicate. Lines without the field only satisfy OpNe.
+func (p FieldPredicate) Match(fields Fields) bool {
+	value, ok := fields.Lookup(p.Field)
+	switch {
+	case p.Op == OpExists:
+		return ok
+	case !ok:
+		return p.Op == OpNe
+	case p.Op == OpEq:
+		return valuesEqual(value, p.Value)
+	case p.Op == OpNe:
+		return !valuesEqual(value, p.Value)
+	case p.Op == OpIn:
+		values, _ := p.Value.([]interface{})
+		for _, v := range values {
+			if valuesEqual(value, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	c := compareValues(value, p.Value)
+	switch p.Op {
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	}
+	return false
+}
+
+// number returns the value as a number if it is one or is a string that holds one.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// valuesEqual returns true if two values are equal. Strings are only compared as numbers when the other value is a
+// number, so `"007"` doesn't equal `"7"`.
+func valuesEqual(a, b interface{}) bool {
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if !aString || !bString {
+		if fa, ok := number(a); ok {
+			if fb, ok := number(b); ok {
+				return fa == fb
+			}
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// compareValues returns -1, 0 or 1 if `a` is less than, equal to or greater than `b`.
+func compareValues(a, b interface{}) int {
+	if fa, ok := number(a); ok {
+		if fb, ok := number(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// MatchField is a filter that parses a line and checks that its fields satisfy a predicate. Lines that can't be
+// parsed aren't included.
+type MatchField struct {
+	parser    LineParser
+	predicate FieldPredicate
+}
+
+// NewMatchField creates a new field match filter. An error is returned if the predicate's operator is unknown or
+// its value doesn't suit the operator.
+func NewMatchField(parser LineParser, predicate FieldPredicate) (*MatchField, error) {
+	if _, err := ParseFieldOp(string(predicate.Op)); err != nil {
+		return nil, err
+	}
+	if predicate.Field == "" {
+		return nil, fmt.Errorf("field name is required")
+	}
+	switch predicate.Value.(type) {
+	case []interface{}:
+		if predicate.Op != OpIn {
+			return nil, fmt.Errorf("field operator %s needs a single value, not a list", predicate.Op)
+		}
+	case nil:
+		if predicate.Op != OpExists && predicate.Op != OpEq && predicate.Op != OpNe {
+			return nil, fmt.Errorf("field operator %s needs a value", predicate.Op)
+		}
+	default:
+		if predicate.Op == OpIn {
+			return nil, fmt.Errorf("field operator %s needs a list of values", predicate.Op)
+		}
+	}
+	return &MatchField{parser: parser, predicate: predicate}, nil
+}
+
+// Include determines if a line of text should be included in the result set.
+func (f *MatchField) Include(s string) bool {
+	fields, ok := f.parser.Parse(s)
+	return ok && f.predicate.Match(fields)
+}
+
+// Parsed is a filter that includes the lines its parser can parse.
+type Parsed struct {
+	Parser LineParser
+}
+
+// Include determines if a line of text should be included in the result set.
+func (f Parsed) Include(s string) bool {
+	_, ok := f.Parser.Parse(s)
+	return ok
+}
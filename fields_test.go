@@ -0,0 +1,235 @@
+package cproject_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestJSONParser(t *testing.T) {
+	testCases := []struct {
+		desc string
+		line string
+		want bool
+	}{
+		{
+			desc: "object",
+			line: `{"level": "error", "status": 503}`,
+			want: true,
+		}, {
+			desc: "objectWithSpace",
+			line: `  {"level": "error"}  `,
+			want: true,
+		}, {
+			desc: "plainText",
+			line: "2024-02-20T07:10:42Z ERROR GET /healthcheck 503",
+			want: false,
+		}, {
+			desc: "array",
+			line: `["error"]`,
+			want: false,
+		}, {
+			desc: "trailingText",
+			line: `{"level": "error"} and more`,
+			want: false,
+		}, {
+			desc: "truncated",
+			line: `{"level": "err`,
+			want: false,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, got := cproject.JSONParser{}.Parse(tC.line)
+			if tC.want != got {
+				t.Errorf("unexpected parse result - want: %t, got: %t", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldsLookup(t *testing.T) {
+	fields, ok := cproject.JSONParser{}.Parse(`{"level": "error", "user": {"id": 42, "org": {"name": "zoo"}}, ` +
+		`"http.status": 503}`)
+	if !ok {
+		t.Fatal("fixture line can't be parsed")
+	}
+
+	testCases := []struct {
+		desc  string
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{desc: "topLevel", path: "level", want: "error", found: true},
+		{desc: "nested", path: "user.id", want: json.Number("42"), found: true},
+		{desc: "deeplyNested", path: "user.org.name", want: "zoo", found: true},
+		{desc: "dottedName", path: "http.status", want: json.Number("503"), found: true},
+		{desc: "missing", path: "user.name", found: false},
+		{desc: "notAnObject", path: "level.name", found: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, found := fields.Lookup(tC.path)
+			if tC.found != found || tC.want != got {
+				t.Errorf("unexpected lookup - want: %#v (%t), got: %#v (%t)", tC.want, tC.found, got, found)
+			}
+		})
+	}
+
+	projected := fields.Project([]string{"level", "user.org.name", "missing"})
+	if len(projected) != 2 || projected["level"] != "error" || projected["user.org.name"] != "zoo" {
+		t.Errorf("unexpected projection: %#v", projected)
+	}
+}
+
+func TestMatchField(t *testing.T) {
+	fxtLine := `{"level": "error", "status": 503, "code": "007", "user": {"id": 42}, "ok": false}`
+	testCases := []struct {
+		desc      string
+		predicate cproject.FieldPredicate
+		line      string
+		want      bool
+	}{
+		{
+			desc:      "eqString",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpEq, Value: "error"},
+			want:      true,
+		}, {
+			desc:      "eqStringMisses",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpEq, Value: "warn"},
+			want:      false,
+		}, {
+			desc:      "eqNumber",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpEq, Value: 503.0},
+			want:      true,
+		}, {
+			desc:      "eqNumberAsString",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpEq, Value: "503"},
+			want:      true,
+		}, {
+			desc:      "eqStringsNotNumbers",
+			predicate: cproject.FieldPredicate{Field: "code", Op: cproject.OpEq, Value: "7"},
+			want:      false,
+		}, {
+			desc:      "eqBool",
+			predicate: cproject.FieldPredicate{Field: "ok", Op: cproject.OpEq, Value: false},
+			want:      true,
+		}, {
+			desc:      "ne",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpNe, Value: "warn"},
+			want:      true,
+		}, {
+			desc:      "neMissing",
+			predicate: cproject.FieldPredicate{Field: "missing", Op: cproject.OpNe, Value: "warn"},
+			want:      true,
+		}, {
+			desc:      "ge",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpGe, Value: 500},
+			want:      true,
+		}, {
+			desc:      "lt",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpLt, Value: 500},
+			want:      false,
+		}, {
+			desc:      "gtMissing",
+			predicate: cproject.FieldPredicate{Field: "missing", Op: cproject.OpGt, Value: 500},
+			want:      false,
+		}, {
+			desc: "inNested",
+			predicate: cproject.FieldPredicate{Field: "user.id", Op: cproject.OpIn,
+				Value: []interface{}{7.0, 42.0}},
+			want: true,
+		}, {
+			desc: "inMisses",
+			predicate: cproject.FieldPredicate{Field: "user.id", Op: cproject.OpIn,
+				Value: []interface{}{7.0, 43.0}},
+			want: false,
+		}, {
+			desc:      "exists",
+			predicate: cproject.FieldPredicate{Field: "user", Op: cproject.OpExists},
+			want:      true,
+		}, {
+			desc:      "notJSON",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpExists},
+			line:      "ERROR level=error",
+			want:      false,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			filter, err := cproject.NewMatchField(cproject.JSONParser{}, tC.predicate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line := fxtLine
+			if tC.line != "" {
+				line = tC.line
+			}
+			if got := filter.Include(line); tC.want != got {
+				t.Errorf("failure to including line - want: %t, got: %t", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestNewMatchFieldError(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		predicate cproject.FieldPredicate
+	}{
+		{
+			desc:      "unknownOp",
+			predicate: cproject.FieldPredicate{Field: "level", Op: "~=", Value: "error"},
+		}, {
+			desc:      "noField",
+			predicate: cproject.FieldPredicate{Op: cproject.OpEq, Value: "error"},
+		}, {
+			desc:      "inWithoutList",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpIn, Value: "error"},
+		}, {
+			desc:      "listWithoutIn",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpEq, Value: []interface{}{"error"}},
+		}, {
+			desc:      "compareWithoutValue",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpGe},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if _, err := cproject.NewMatchField(cproject.JSONParser{}, tC.predicate); err == nil {
+				t.Errorf("no error returned - expected error for %#v", tC.predicate)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		format  string
+		want    cproject.LineParser
+		wantErr bool
+	}{
+		{desc: "default", format: "", want: cproject.JSONParser{}},
+		{desc: "json", format: "json", want: cproject.JSONParser{}},
+		{desc: "logfmt", format: "logfmt", want: cproject.LogfmtParser{}},
+		{desc: "syslog", format: "syslog", want: cproject.SyslogParser{}},
+		{desc: "rfc5424", format: "rfc5424", want: cproject.RFC5424Parser{}},
+		{desc: "common", format: "common", want: cproject.CommonLogParser{}},
+		{desc: "combined", format: "combined", want: cproject.CombinedLogParser{}},
+		{desc: "unknown", format: "yaml", wantErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, err := cproject.ParseFormat(tC.format)
+			if tC.wantErr != (err != nil) {
+				t.Fatalf("unexpected error - want error: %t, got: %v", tC.wantErr, err)
+			}
+			if tC.want != got {
+				t.Errorf("unexpected parser - want: %T, got: %T", tC.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cproject
+
+import "os"
+
+// fileID returns the zero FileID; files can't be identified independent of their paths on this platform.
+func fileID(info os.FileInfo) FileID {
+	return FileID{}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cproject
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the identity of a file from its device and inode numbers.
+func fileID(info os.FileInfo) FileID {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}
+	}
+	return FileID{Device: uint64(stat.Dev), Inode: uint64(stat.Ino)}
+}
@@ -1,6 +1,13 @@
 package cproject
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
 
 // Filter describes the behavior of a log file filter.
 type Filter interface {
@@ -60,3 +67,181 @@ func (f *MatchAnySubstring) Include(s string) bool {
 	}
 	return false
 }
+
+// All is a filter that includes a line only if all of its filters include it. An empty All includes every line.
+type All []Filter
+
+// Include determines if a line of text should be included in the result set.
+func (f All) Include(s string) bool {
+	for _, filter := range f {
+		if !filter.Include(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Any is a filter that includes a line if at least one of its filters includes it. An empty Any includes no lines.
+type Any []Filter
+
+// Include determines if a line of text should be included in the result set.
+func (f Any) Include(s string) bool {
+	for _, filter := range f {
+		if filter.Include(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Not is a filter that includes a line only if its filter doesn't include it.
+type Not struct {
+	Filter Filter
+}
+
+// Include determines if a line of text should be included in the result set.
+func (f Not) Include(s string) bool {
+	return !f.Filter.Include(s)
+}
+
+// RegexpError is returned when a regular expression filter can't be compiled. Position is the byte offset in the
+// pattern where the problem was found.
+type RegexpError struct {
+	Pattern  string `json:"pattern"`
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+	err      error
+}
+
+// Error returns the error message.
+func (e *RegexpError) Error() string {
+	return fmt.Sprintf("invalid regular expression at position %d: %s", e.Position, e.Message)
+}
+
+// Unwrap returns the underlying compile error.
+func (e *RegexpError) Unwrap() error {
+	return e.err
+}
+
+// MatchRegexp is a filter that checks that a line matches a regular expression (RE2 syntax). The expression is
+// compiled once when the filter is created.
+type MatchRegexp struct {
+	re *regexp.Regexp
+}
+
+// NewMatchRegexp creates a new regular expression match filter. A *RegexpError is returned if the pattern can't be
+// compiled.
+func NewMatchRegexp(pattern string) (*MatchRegexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		rerr := &RegexpError{Pattern: pattern, Message: err.Error(), err: err}
+		var serr *syntax.Error
+		if errors.As(err, &serr) {
+			rerr.Position = regexpErrorPosition(pattern, serr)
+		}
+		return nil, rerr
+	}
+	return &MatchRegexp{re: re}, nil
+}
+
+// regexpErrorPosition returns the byte offset in the pattern of the problem a compile error describes. Unbalanced
+// parens are found by scanning the pattern, since the error only has the whole pattern. Otherwise the error has the
+// offending part of the pattern, which may be found more than once; it's the first part the pattern fails with the
+// same error at when it's cut off right after it. The pattern is parsed left to right, so cut off after any later
+// part it fails the same way, and the part is found with a binary search rather than by cutting it off after each.
+func regexpErrorPosition(pattern string, serr *syntax.Error) int {
+	switch serr.Code {
+	case syntax.ErrMissingParen:
+		return unbalancedParen(pattern, false)
+	case syntax.ErrUnexpectedParen:
+		return unbalancedParen(pattern, true)
+	case syntax.ErrTrailingBackslash:
+		return len(pattern) - 1
+	}
+	if serr.Expr == "" {
+		return 0
+	}
+
+	found := []int{}
+	for pos := 0; pos <= len(pattern)-len(serr.Expr); pos++ {
+		i := strings.Index(pattern[pos:], serr.Expr)
+		if i < 0 {
+			break
+		}
+		pos += i
+		found = append(found, pos)
+	}
+	i := sort.Search(len(found), func(i int) bool {
+		_, err := syntax.Parse(pattern[:found[i]+len(serr.Expr)], syntax.Perl)
+		var cut *syntax.Error
+		return errors.As(err, &cut) && cut.Code == serr.Code && cut.Expr == serr.Expr
+	})
+	if i == len(found) {
+		return 0
+	}
+	return found[i]
+}
+
+// unbalancedParen returns the offset of the last `(` in the pattern that isn't closed or, if `closing` is true, of
+// the first `)` that doesn't close one. Escaped and quoted parens and parens in character classes don't count.
+func unbalancedParen(pattern string, closing bool) int {
+	open := []int{}
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			// text is quoted from `\Q` up to `\E` or the end of the pattern.
+			if strings.HasPrefix(pattern[i:], `\Q`) {
+				end := strings.Index(pattern[i:], `\E`)
+				if end < 0 {
+					end = len(pattern) - i
+				}
+				i += end
+			}
+			i++
+		case '[':
+			i = classEnd(pattern, i)
+		case '(':
+			open = append(open, i)
+		case ')':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			} else if closing {
+				return i
+			}
+		}
+	}
+	if !closing && len(open) > 0 {
+		return open[len(open)-1]
+	}
+	return 0
+}
+
+// classEnd returns the offset of the `]` that ends the character class that starts at `start` in the pattern, or the
+// length of the pattern if it doesn't end.
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if strings.HasPrefix(pattern[i:], "^") {
+		i++
+	}
+	// a `]` straight after the `[` (or `[^`) is part of the class.
+	if strings.HasPrefix(pattern[i:], "]") {
+		i++
+	}
+	for i < len(pattern) && pattern[i] != ']' {
+		switch {
+		case pattern[i] == '\\':
+			i++
+		case strings.HasPrefix(pattern[i:], "[:"):
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += end + 3
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// Include determines if a line of text should be included in the result set.
+func (f *MatchRegexp) Include(s string) bool {
+	return f.re.MatchString(s)
+}
@@ -1,7 +1,10 @@
 package cproject_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/marklap/cproject"
 )
@@ -60,3 +63,177 @@ func TestMatchAnySubstring(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchRegexp(t *testing.T) {
+	fxtLine := "Leonardo, Donatello, Raphael and Michelangelo"
+	testCases := []struct {
+		desc    string
+		pattern string
+		want    bool
+	}{
+		{
+			desc:    "prefix",
+			pattern: `^Leo`,
+			want:    true,
+		}, {
+			desc:    "alternation",
+			pattern: `Splinter|Raph\w+`,
+			want:    true,
+		}, {
+			desc:    "caseInsensitive",
+			pattern: `(?i)michelangelo$`,
+			want:    true,
+		}, {
+			desc:    "noMatch",
+			pattern: `^Donatello`,
+			want:    false,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			filter, err := cproject.NewMatchRegexp(tC.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := filter.Include(fxtLine)
+			if tC.want != got {
+				t.Errorf("failure to including line - want: %t, got: %t", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestNewMatchRegexpError(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		pattern  string
+		position int
+	}{
+		{
+			desc:     "missingParen",
+			pattern:  `a(b`,
+			position: 1,
+		}, {
+			desc:     "missingParenNested",
+			pattern:  `(a)(b(c)`,
+			position: 3,
+		}, {
+			desc:     "missingParenEscaped",
+			pattern:  `\(a[(]\Q(\E(b`,
+			position: 11,
+		}, {
+			desc:     "unexpectedParen",
+			pattern:  `(a)b)`,
+			position: 4,
+		}, {
+			desc:     "missingBracket",
+			pattern:  `a[]b`,
+			position: 1,
+		}, {
+			desc:     "nestedRepetition",
+			pattern:  `ab**`,
+			position: 2,
+		}, {
+			desc:     "badClassRange",
+			pattern:  `ERROR [z-a]`,
+			position: 7,
+		}, {
+			desc:     "badClassRangeRepeated",
+			pattern:  `z-a[z-a]`,
+			position: 4,
+		}, {
+			desc:     "trailingBackslash",
+			pattern:  `ab\`,
+			position: 2,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := cproject.NewMatchRegexp(tC.pattern)
+			var rerr *cproject.RegexpError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("unexpected error - want: *cproject.RegexpError, got: %#v", err)
+			}
+			if tC.position != rerr.Position {
+				t.Errorf("unexpected error position - want: %d, got: %d (%s)", tC.position, rerr.Position, rerr)
+			}
+		})
+	}
+}
+
+func TestNewMatchRegexpErrorLongPattern(t *testing.T) {
+	// the range of the class is the last of many parts of the pattern the error could be in.
+	pattern := strings.Repeat("z-a", 32000) + "[z-a]"
+	start := time.Now()
+	_, err := cproject.NewMatchRegexp(pattern)
+	elapsed := time.Since(start)
+
+	var rerr *cproject.RegexpError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("unexpected error - want: *cproject.RegexpError, got: %#v", err)
+	}
+	if want := len(pattern) - len("z-a]"); rerr.Position != want {
+		t.Errorf("unexpected error position - want: %d, got: %d", want, rerr.Position)
+	}
+	if elapsed > time.Second {
+		t.Errorf("finding the error position took too long: %s", elapsed)
+	}
+}
+
+func TestFilterComposition(t *testing.T) {
+	fxtLine := "2024-02-20T07:10:42Z ERROR GET /healthcheck 503"
+	errorFilter := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+	healthFilter := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"healthcheck"}))
+	warnFilter := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"WARN"}))
+	testCases := []struct {
+		desc   string
+		filter cproject.Filter
+		want   bool
+	}{
+		{
+			desc:   "allMatch",
+			filter: cproject.All{errorFilter, healthFilter},
+			want:   true,
+		}, {
+			desc:   "allOneMisses",
+			filter: cproject.All{errorFilter, warnFilter},
+			want:   false,
+		}, {
+			desc:   "allEmpty",
+			filter: cproject.All{},
+			want:   true,
+		}, {
+			desc:   "anyOneMatches",
+			filter: cproject.Any{warnFilter, healthFilter},
+			want:   true,
+		}, {
+			desc:   "anyNoneMatch",
+			filter: cproject.Any{warnFilter},
+			want:   false,
+		}, {
+			desc:   "anyEmpty",
+			filter: cproject.Any{},
+			want:   false,
+		}, {
+			desc:   "not",
+			filter: cproject.Not{warnFilter},
+			want:   true,
+		}, {
+			desc:   "errorAndNotHealthcheck",
+			filter: cproject.All{errorFilter, cproject.Not{healthFilter}},
+			want:   false,
+		}, {
+			desc:   "nested",
+			filter: cproject.Any{warnFilter, cproject.All{errorFilter, cproject.Not{cproject.Not{healthFilter}}}},
+			want:   true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := tC.filter.Include(fxtLine)
+			if tC.want != got {
+				t.Errorf("failure to including line - want: %t, got: %t", tC.want, got)
+			}
+		})
+	}
+}
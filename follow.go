@@ -0,0 +1,348 @@
+package cproject
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultPollInterval is how often a followed file is checked for appended lines.
+	DefaultPollInterval = 250 * time.Millisecond
+)
+
+// follower reads lines appended to a file. Bytes after the last newline are held back until the rest of the line
+// has been written. The follower keeps an eye on the path of the file so it can tell when the file has been rotated
+// (renamed and recreated) or truncated.
+type follower struct {
+	path string
+	file *os.File
+	// owned is true when the follower opened the file itself (after a rotation) and is responsible for closing it.
+	owned bool
+	// offset is the offset of the first byte that hasn't been yielded as part of a line.
+	offset int64
+	// partial holds the bytes of a line that has been read but not yet terminated by a newline.
+	partial []byte
+	// lineNumbers is true when lines are numbered.
+	lineNumbers bool
+	// number is the line number of the line at offset, or 0 if it isn't known.
+	number int64
+	// midLine is true until the first newline is read when the follower starts partway through a line whose start
+	// has already been read (the unterminated last line of the initial lines). An empty line that newline ends is the
+	// rest of that line rather than a blank line, so it isn't passed along.
+	midLine bool
+	// buf is the read buffer.
+	buf []byte
+}
+
+// newFollower creates a follower that reads the file at `path` starting at `offset`.
+func newFollower(path string, file *os.File, offset int64) *follower {
+	return &follower{
+		path:   path,
+		file:   file,
+		offset: offset,
+		buf:    make([]byte, stdBufSize),
+	}
+}
+
+// readAppended reads everything appended to the file since the last read. Every complete line is passed to `fn`;
+// reading stops early when `fn` returns false. It returns false if `fn` asked to stop.
+func (f *follower) readAppended(fn func(Line) bool) (bool, error) {
+	for {
+		n, err := f.file.ReadAt(f.buf, f.offset+int64(len(f.partial)))
+		if err != nil && err != io.EOF {
+			return true, err
+		}
+
+		start := 0
+		for i := 0; i < n; i++ {
+			if f.buf[i] != newline {
+				continue
+			}
+			f.partial = append(f.partial, f.buf[start:i]...)
+			line := Line{Text: string(f.partial), Offset: f.offset, Number: f.number}
+			f.offset += int64(len(f.partial)) + 1
+			f.partial = f.partial[:0]
+			if f.number > 0 {
+				f.number++
+			}
+			start = i + 1
+			rest := f.midLine && line.Text == ""
+			f.midLine = false
+			if !rest && !fn(line) {
+				return false, nil
+			}
+		}
+		f.partial = append(f.partial, f.buf[start:n]...)
+
+		if n < len(f.buf) {
+			return true, nil
+		}
+	}
+}
+
+// rotated returns true if the path of the followed file now refers to a different file than the one being read. A
+// missing path is not a rotation; the new file may not have been created yet.
+func (f *follower) rotated() (bool, error) {
+	pathStat, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	fileStat, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return !os.SameFile(pathStat, fileStat), nil
+}
+
+// truncated returns true if the file being read has shrunk below what has already been read.
+func (f *follower) truncated() (bool, error) {
+	stat, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return stat.Size() < f.offset+int64(len(f.partial)), nil
+}
+
+// flushPartial returns the unterminated line that's been read, if any, and forgets it.
+func (f *follower) flushPartial() (Line, bool) {
+	if len(f.partial) == 0 {
+		return Line{}, false
+	}
+	line := Line{Text: string(f.partial), Offset: f.offset, Number: f.number}
+	f.offset += int64(len(f.partial))
+	f.partial = f.partial[:0]
+	return line, true
+}
+
+// rewind starts reading the file from the beginning again.
+func (f *follower) rewind() {
+	f.offset = 0
+	f.partial = f.partial[:0]
+	f.midLine = false
+	if f.lineNumbers {
+		f.number = 1
+	}
+}
+
+// reopen opens the file at the followed path and starts reading it from the beginning.
+func (f *follower) reopen() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	f.close()
+	f.file = file
+	f.owned = true
+	f.rewind()
+	return nil
+}
+
+// close closes the file being read if the follower opened it.
+func (f *follower) close() {
+	if f.owned {
+		f.file.Close()
+	}
+}
+
+// checkReset checks whether the followed file has been rotated or truncated. A rotated file is read to the end, with
+// lines passed to `fn`, before the new file is opened. It returns the kind of marker that should be yielded or
+// LineText if neither happened.
+func (f *follower) checkReset(fn func(Line) bool) (LineKind, error) {
+	rotated, err := f.rotated()
+	if err != nil {
+		return LineText, err
+	}
+	if rotated {
+		// catch anything written to the old file between the last read and the rotation.
+		if _, err := f.readAppended(fn); err != nil {
+			return LineText, err
+		}
+		if line, ok := f.flushPartial(); ok {
+			fn(line)
+		}
+		if err := f.reopen(); err != nil {
+			return LineText, err
+		}
+		return LineRotated, nil
+	}
+
+	truncated, err := f.truncated()
+	if err != nil {
+		return LineText, err
+	}
+	if truncated {
+		f.rewind()
+		return LineTruncated, nil
+	}
+
+	return LineText, nil
+}
+
+// sendLine sends the line to the lines channel unless the context is done first. It returns false if the context
+// is done.
+func sendLine(ctx context.Context, lines chan<- Line, line Line) bool {
+	select {
+	case lines <- line:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// followLines yields up to `numLines` lines from the end of the file in the order they are found in the file and then
+// yields lines as they are appended to the file until the context is done. Only lines that pass the filters are
+// yielded. When the file at `path` is rotated, the rest of the old file is read and the new file is followed from the
+// beginning; when the file is truncated it's followed from the beginning. Either way a marker line (LineRotated or
+// LineTruncated) is yielded before any lines from the new content. The lines channel and `errChan` are closed when
+// following stops; an error is only sent on `errChan` if following stopped because of it - the context being done is
+// not an error. If `since` isn't zero, the initial lines are limited to those with timestamps from `since` on. If
+// `lineNumbers` is true, lines are numbered when the initial lines are near enough to the start of the file to count
+// (see lineCounter); appended lines are numbered by counting on from there. If `from` isn't negative, the initial
+// lines are skipped and following starts at the first line that starts at or after the offset `from` instead.
+func followLines(ctx context.Context, path string, file *os.File, numLines int, filters []Filter, since time.Time,
+	from int64, pollInterval time.Duration, lineNumbers bool, lines chan<- Line, errChan chan<- error) {
+	defer close(errChan)
+	defer close(lines)
+
+	offset, counter, err := followStart(ctx, file, numLines, filters, since, from, lineNumbers, lines)
+	if err != nil {
+		if ctx.Err() == nil {
+			errChan <- err
+		}
+		return
+	}
+
+	f := newFollower(path, file, offset)
+	defer f.close()
+	if f.midLine, err = midLine(file, offset); err != nil {
+		errChan <- err
+		return
+	}
+	if lineNumbers {
+		f.lineNumbers = true
+		// the follower starts at the end of the newest initial line (or the end of the file), which is still part of
+		// the line it ends, or at the start of the line it resumes from.
+		if f.number, err = counter.lineNumber(offset); err != nil {
+			errChan <- err
+			return
+		}
+	}
+	emit := func(line Line) bool {
+		if !passesFilters(line.Text, filters) {
+			return true
+		}
+		return sendLine(ctx, lines, line)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := f.readAppended(emit)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if !ok {
+			return
+		}
+
+		marker, err := f.checkReset(emit)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if marker != LineText {
+			if !sendLine(ctx, lines, Line{Kind: marker}) {
+				return
+			}
+			// read the new content straight away
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// midLine returns true if `offset` is partway through a line of the file, rather than at the start of one or past
+// the end of the file.
+func midLine(file *os.File, offset int64) (bool, error) {
+	if offset == 0 {
+		return false, nil
+	}
+	b := make([]byte, 1)
+	if _, err := file.ReadAt(b, offset-1); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return b[0] != newline, nil
+}
+
+// followStart yields the initial lines of a followed file (see followLines) and returns the offset to follow the file
+// from, along with the line counter that numbered the initial lines.
+func followStart(ctx context.Context, file *os.File, numLines int, filters []Filter, since time.Time, from int64,
+	lineNumbers bool, lines chan<- Line) (int64, *lineCounter, error) {
+	counter := newLineCounter(file)
+	if from >= 0 {
+		// just past the end of a file that doesn't end with a newline is the end of its unterminated last line, as if
+		// it had one; anything further is seen as a truncation once following starts.
+		stat, err := file.Stat()
+		if err != nil {
+			return 0, nil, err
+		}
+		size := stat.Size()
+		if from == size+1 {
+			unterminated, err := midLine(file, size)
+			if err != nil {
+				return 0, nil, err
+			}
+			if unterminated {
+				from = size
+			}
+		}
+		// the follower reads everything from the start of the line on, or from the end of the file when the offset is
+		// in its unterminated last line.
+		offset, err := lineStart(file, from)
+		if from <= size && offset > size {
+			offset = size
+		}
+		return offset, counter, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	within, err := timeSpan(file, since, time.Time{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Follow from the end of the file as it was before the initial lines were read or the end of the newest of
+	// the initial lines, whichever is further along, so appended lines aren't yielded twice.
+	offset := stat.Size()
+	send := func(line Line) bool {
+		if end := line.Offset + int64(len(line.Text)); end > offset {
+			offset = end
+		}
+		return sendLine(ctx, lines, line)
+	}
+	if lineNumbers {
+		send = counter.numbered(send)
+	}
+	err = tailLines(ctx, file, within, numLines, filters, OrderForward, send)
+	if err == nil {
+		err = counter.err
+	}
+	return offset, counter, err
+}
@@ -0,0 +1,309 @@
+package cproject_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// receiveLines reads `n` lines from the channel failing the test if they don't arrive in time. Markers are returned as
+// their kind in angle brackets.
+func receiveLines(t *testing.T, lines chan cproject.Line, n int) []string {
+	t.Helper()
+	got := []string{}
+	for len(got) < n {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("lines channel closed early - got: %#v", got)
+			}
+			if line.Kind != cproject.LineText {
+				got = append(got, "<"+line.Kind.String()+">")
+				continue
+			}
+			got = append(got, line.Text)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for lines - got: %#v", got)
+		}
+	}
+	return got
+}
+
+func TestLogFileFollow(t *testing.T) {
+	content := cproject.FxtContent()
+	file, err := cproject.FxtFile(t, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.NewLogFile(file.Name(), cproject.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filter := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"things", "errors", "new"}))
+	lines, errChan := logFile.Follow(ctx, 2, filter)
+
+	want := []string{"naming things,", "and off-by-1 errors."}
+	if got := receiveLines(t, lines, 2); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected initial lines - want: %#v, got: %#v", want, got)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	// the first newline terminates the last line of the fixture; the partial line is held back until it's finished.
+	if _, err := file.WriteString("\nnew line one\nskipped line\nnew line"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"new line one"}
+	if got := receiveLines(t, lines, 1); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected appended lines - want: %#v, got: %#v", want, got)
+	}
+
+	if _, err := file.WriteString(" two\n"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"new line two"}
+	if got := receiveLines(t, lines, 1); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected appended lines - want: %#v, got: %#v", want, got)
+	}
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error after cancel: %s", err)
+	}
+}
+
+func TestLogFileFollowBlankLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("first\nunterminated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.NewLogFile(path, cproject.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, errChan := logFile.Follow(ctx, 10)
+	want := []string{"first", "unterminated"}
+	if got := receiveLines(t, lines, 2); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected initial lines - want: %#v, got: %#v", want, got)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	// the first newline ends the unterminated line, which has already been yielded; the blank line after it is yielded.
+	if _, err := file.WriteString("\n\nafter blank\n"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"", "after blank"}
+	if got := receiveLines(t, lines, 2); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected appended lines - want: %#v, got: %#v", want, got)
+	}
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error after cancel: %s", err)
+	}
+}
+
+func TestLogFileFollowRotated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.NewLogFile(path, cproject.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, errChan := logFile.Follow(ctx, 10)
+	want := []string{"first"}
+	if got := receiveLines(t, lines, 1); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected initial lines - want: %#v, got: %#v", want, got)
+	}
+
+	// rotate the file the way logrotate does: rename, a last write to the old handle, then create a new file.
+	old, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.WriteString("last\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("fresh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"last", "<rotated>", "fresh"}
+	if got := receiveLines(t, lines, 3); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected lines after rotation - want: %#v, got: %#v", want, got)
+	}
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error after cancel: %s", err)
+	}
+}
+
+func TestLogFileFollowTruncated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("first line\nsecond line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.NewLogFile(path, cproject.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, errChan := logFile.Follow(ctx, 10)
+	want := []string{"first line", "second line"}
+	if got := receiveLines(t, lines, 2); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected initial lines - want: %#v, got: %#v", want, got)
+	}
+
+	// copytruncate: the file keeps its identity but starts over.
+	if err := os.WriteFile(path, []byte("after\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"<truncated>", "after"}
+	if got := receiveLines(t, lines, 2); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected lines after truncation - want: %#v, got: %#v", want, got)
+	}
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error after cancel: %s", err)
+	}
+}
+
+func TestLogFileFollowFrom(t *testing.T) {
+	content := "first line\nsecond line\nthird line\n"
+
+	unterminated := "first line\nsecond line"
+
+	testCases := []struct {
+		desc string
+		// content and appended replace the content of the file, and what's appended to it, if they aren't empty.
+		content  string
+		appended string
+		from     int64
+		want     []string
+	}{
+		{
+			desc: "lineStart",
+			from: 11,
+			want: []string{"second line", "third line", "appended"},
+		}, {
+			desc: "midLine",
+			from: 12,
+			want: []string{"third line", "appended"},
+		}, {
+			desc: "end",
+			from: int64(len(content)),
+			want: []string{"appended"},
+		}, {
+			desc: "pastEnd",
+			from: 100,
+			want: []string{"<truncated>", "first line", "second line", "third line", "appended"},
+		}, {
+			// just past an unterminated last line is where its newline would be.
+			desc:     "unterminatedEnd",
+			content:  unterminated,
+			appended: "\nappended\n",
+			from:     int64(len(unterminated)) + 1,
+			want:     []string{"appended"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			fileContent := content
+			if tC.content != "" {
+				fileContent = tC.content
+			}
+			if err := os.WriteFile(path, []byte(fileContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			logFile, err := cproject.NewLogFile(path, cproject.WithPollInterval(10*time.Millisecond),
+				cproject.WithFollowFrom(tC.from))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { logFile.Close() })
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			lines, errChan := logFile.Follow(ctx, 1)
+			appended, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer appended.Close()
+			appendedContent := "appended\n"
+			if tC.appended != "" {
+				appendedContent = tC.appended
+			}
+			if _, err := appended.WriteString(appendedContent); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := receiveLines(t, lines, len(tC.want)); !cproject.StringSlicesEqual(tC.want, got) {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tC.want, got)
+			}
+
+			cancel()
+			for range lines {
+			}
+			if err := <-errChan; err != nil {
+				t.Errorf("unexpected error after cancel: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Tail requests followed over websocket connections, and the messages that control them, for handlers.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// wsRequestTimeout is how long a client has to send its tail request after opening a websocket connection.
+const wsRequestTimeout = 30 * time.Second
+
+// ControlAction is what a control message does to a tail request followed over a websocket connection.
+type ControlAction string
+
+const (
+	// ControlFilter replaces the filters of the tail request.
+	ControlFilter ControlAction = "filter"
+	// ControlNumLines changes the number of lines of the tail request.
+	ControlNumLines ControlAction = "num_lines"
+	// ControlPause stops lines being sent until the tail request is resumed.
+	ControlPause ControlAction = "pause"
+	// ControlResume sends lines again after the tail request was paused.
+	ControlResume ControlAction = "resume"
+)
+
+// ControlMessage is a message a client sends over a websocket connection to change the tail request it's following.
+// The filter fields replace those of the tail request for `filter` actions and `num_lines` replaces its number of
+// lines for `num_lines` actions; either way following starts over with the last lines of the log file.
+type ControlMessage struct {
+	Action          ControlAction `json:"action"`
+	MatchSubstrings []string      `json:"match_substrings"`
+	CaseSensitive   bool          `json:"case_sensitive"`
+	MatchRegex      string        `json:"match_regex"`
+	Filter          *FilterSpec   `json:"filter"`
+	MinLevel        string        `json:"min_level"`
+	NumLines        int           `json:"num_lines"`
+}
+
+// tailSession is a tail request followed over a websocket connection.
+type tailSession struct {
+	plan         *tailPlan
+	host         string
+	requestID    string
+	pathPrefixes []string
+	paused       bool
+}
+
+// newTailSession validates a tail request to follow over a websocket connection and creates its session.
+func newTailSession(req *TailRequest, host, requestID string, pathPrefixes []string) (*tailSession, error) {
+	req.Follow = true
+	plan, err := validateTailRequest(req, pathPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	return &tailSession{plan: plan, host: host, requestID: requestID, pathPrefixes: pathPrefixes}, nil
+}
+
+// control applies a control message to the session. It returns the event that announces the change and whether
+// following has to start over. The session is left as it was if the message can't be applied.
+func (s *tailSession) control(msg ControlMessage) (string, bool, error) {
+	switch msg.Action {
+	case ControlPause:
+		s.paused = true
+		return "paused", false, nil
+	case ControlResume:
+		s.paused = false
+		return "resumed", false, nil
+	case ControlFilter, ControlNumLines:
+	default:
+		return "", false, fmt.Errorf("unknown control action: %q", msg.Action)
+	}
+
+	req := s.plan.req
+	if msg.Action == ControlFilter {
+		req.MatchSubstrings, req.CaseSensitive = msg.MatchSubstrings, msg.CaseSensitive
+		req.MatchRegex, req.Filter, req.MinLevel = msg.MatchRegex, msg.Filter, msg.MinLevel
+	} else {
+		req.NumLines = msg.NumLines
+	}
+	plan, err := validateTailRequest(&req, s.pathPrefixes)
+	if err != nil {
+		return "", false, err
+	}
+	s.plan = plan
+	return "restarted", true, nil
+}
+
+// follow starts following the log file. The returned function stops following and closes the log file.
+func (s *tailSession) follow(ctx context.Context) (chan cproject.Line, chan error, func(), error) {
+	follower, err := s.plan.openFollower(-1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	lines, errChan := follower.Follow(ctx, s.plan.numLines, s.plan.filters()...)
+	stop := func() {
+		cancel()
+		for range lines {
+		}
+		<-errChan
+		follower.Close()
+	}
+	return lines, errChan, stop, nil
+}
+
+// run streams lines to the client, and applies the control messages it sends, until the client closes the connection,
+// following fails or the context is done. It returns the number of line bytes written.
+func (s *tailSession) run(ctx context.Context, logger *log.Logger, conn *wsConn, messages <-chan []byte,
+	readErr <-chan error) (int64, error) {
+	lines, errChan, stop, err := s.follow(ctx)
+	if err != nil {
+		return 0, refuseWebSocket(conn, err, CodeInternalError, s.requestID)
+	}
+	defer func() { stop() }()
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	lineBytesOut := int64(0)
+	for {
+		// lines aren't received while paused, which holds following back until the session is resumed.
+		in := lines
+		if s.paused {
+			in = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return lineBytesOut, nil
+		case err := <-readErr:
+			return lineBytesOut, err
+		case <-heartbeat.C:
+			if err := conn.writeFrame(wsPing, nil); err != nil {
+				return lineBytesOut, err
+			}
+		case message := <-messages:
+			var msg ControlMessage
+			err := json.Unmarshal(message, &msg)
+			event, restart := "", false
+			if err == nil {
+				event, restart, err = s.control(msg)
+			}
+			if err != nil {
+				logger.Printf("bad websocket control message - error: %s", err)
+				apiErr := newAPIError(err, CodeInvalidRequest, s.requestID)
+				if err := conn.writeJSON(&ErrorResponse{apiErr}); err != nil {
+					return lineBytesOut, err
+				}
+				continue
+			}
+			logger.Printf("websocket control message: %s", message)
+			if restart {
+				stop()
+				if lines, errChan, stop, err = s.follow(ctx); err != nil {
+					stop = func() {}
+					return lineBytesOut, refuseWebSocket(conn, err, CodeInternalError, s.requestID)
+				}
+			}
+			if err := conn.writeJSON(&TailResponseChunk{Host: s.host, Event: event}); err != nil {
+				return lineBytesOut, err
+			}
+		case line, ok := <-in:
+			if !ok {
+				// following only stops without an error when the context is done.
+				return lineBytesOut, <-errChan
+			}
+			lineBytesOut += int64(len(line.Text))
+			if err := conn.writeJSON(s.plan.chunk(s.host, line)); err != nil {
+				return lineBytesOut, err
+			}
+		}
+	}
+}
+
+// refuseWebSocket answers the client with an error message for a request that can't be followed (any longer) and
+// returns the error that closes the connection: a policy violation, or an internal error for errors on the server's
+// side. The error is reported with its code, or `fallback` if it doesn't have one.
+func refuseWebSocket(conn *wsConn, err error, fallback ErrorCode, requestID string) error {
+	apiErr := newAPIError(err, fallback, requestID)
+	conn.writeJSON(&ErrorResponse{apiErr})
+	if apiErr.Code == CodeInternalError {
+		return &wsCloseError{wsCloseInternalError, err.Error()}
+	}
+	return &wsCloseError{wsClosePolicy, err.Error()}
+}
+
+// readMessages reads messages from the client and passes them on until reading fails (or the client closes the
+// connection) or the context is done. The error reading stopped with is sent on `readErr`.
+func readMessages(ctx context.Context, conn *wsConn, messages chan<- []byte, readErr chan<- error) {
+	for {
+		_, message, err := conn.readMessage()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		select {
+		case messages <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WebSocketHandler handles requests to follow a log file over a websocket connection. The client sends a tail request
+// as the first message and then control messages (see ControlMessage) to change it while lines are sent back as
+// messages of their own. Followed files are streamed for no longer than `maxFollow`.
+func WebSocketHandler(logger *log.Logger, host string, pathPrefixes []string, maxFollow time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := setRequestID(w, r)
+		logger := requestLogger(logger, requestID)
+
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			logger.Printf("bad websocket request - error: %s", err)
+			WriteJSONError(w, err)
+			return
+		}
+		defer conn.close()
+		start := time.Now()
+
+		ctx, cancel := context.WithTimeout(r.Context(), maxFollow)
+		defer cancel()
+		messages := make(chan []byte)
+		readErr := make(chan error, 1)
+		readDone := make(chan struct{})
+
+		// a tail request that can't be followed is answered with an error message before the connection is closed.
+		refuse := func(err error) (int64, error) {
+			return 0, refuseWebSocket(conn, err, CodeInvalidRequest, requestID)
+		}
+		lineBytesOut, err := func() (int64, error) {
+			// the first message is the tail request.
+			conn.conn.SetReadDeadline(time.Now().Add(wsRequestTimeout))
+			_, message, err := conn.readMessage()
+			if err != nil {
+				close(readDone)
+				var closeErr *wsCloseError
+				if errors.Is(err, io.EOF) || errors.As(err, &closeErr) {
+					return 0, err
+				}
+				return refuse(fmt.Errorf("no tail request: %w", err))
+			}
+			conn.conn.SetReadDeadline(time.Time{})
+			go func() {
+				defer close(readDone)
+				readMessages(ctx, conn, messages, readErr)
+			}()
+
+			var req TailRequest
+			if err := json.Unmarshal(message, &req); err != nil {
+				return refuse(err)
+			}
+			logger.Printf("websocket request: %s", req.String())
+			session, err := newTailSession(&req, host, requestID, pathPrefixes)
+			if err != nil {
+				return refuse(err)
+			}
+			return session.run(ctx, logger, conn, messages, readErr)
+		}()
+
+		// close the connection with a close code that says why.
+		code, reason := wsCloseNormal, ""
+		var closeErr *wsCloseError
+		switch {
+		case err == nil || errors.Is(err, io.EOF):
+			err = nil
+		case errors.As(err, &closeErr):
+			code, reason = closeErr.code, closeErr.reason
+		default:
+			code, reason = wsCloseInternalError, err.Error()
+		}
+		cancel()
+		conn.closeWith(code, reason)
+		<-readDone
+
+		if err != nil {
+			logger.Printf("websocket request - error: %s", err)
+			return
+		}
+		logger.Printf("websocket request - line bytes out written: %d [took %s]", lineBytesOut, time.Since(start))
+	})
+}
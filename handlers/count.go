@@ -0,0 +1,43 @@
+// Line counts for handlers.
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// CountResponse is the response to a tail request with mode `count`: a summary of the lines that match the filters
+// rather than the lines themselves.
+type CountResponse struct {
+	Host           string     `json:"host"`
+	MatchedLines   int64      `json:"matched_lines"`
+	ScannedLines   int64      `json:"scanned_lines"`
+	ScannedBytes   int64      `json:"scanned_bytes"`
+	ElapsedMs      float64    `json:"elapsed_ms"`
+	FirstTimestamp *time.Time `json:"first_timestamp,omitempty"`
+	LastTimestamp  *time.Time `json:"last_timestamp,omitempty"`
+}
+
+// countLines counts the lines of the log file, or of a range of it, that pass the filters.
+func countLines(ctx context.Context, logFile cproject.LogFileReader, host string, rng *cproject.Range,
+	filters []cproject.Filter) (*CountResponse, error) {
+	start := time.Now()
+	counts, err := cproject.CountLines(ctx, logFile, rng, filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CountResponse{
+		Host:         host,
+		MatchedLines: counts.Matched,
+		ScannedLines: counts.ScannedLines,
+		ScannedBytes: counts.ScannedBytes,
+		ElapsedMs:    float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if !counts.First.IsZero() {
+		resp.FirstTimestamp, resp.LastTimestamp = &counts.First, &counts.Last
+	}
+	return resp, nil
+}
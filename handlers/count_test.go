@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+func TestTailCount(t *testing.T) {
+	lines := []string{
+		"2024-02-20T07:10:40Z INFO started\n",
+		"\n",
+		"2024-02-20T07:10:41Z ERROR disk full\n",
+		"error without a timestamp\n",
+		"2024-02-20T07:10:42Z ERROR disk slow\n",
+	}
+	content := strings.Join(lines, "")
+	tests := []struct {
+		name string
+		body string
+		// want is the response, leaving out the host and elapsed time; the timestamps are RFC3339.
+		want      handlers.CountResponse
+		wantFirst string
+		wantLast  string
+	}{
+		{
+			name: "all lines",
+			body: `{"path": "%s", "mode": "count"}`,
+			want: handlers.CountResponse{MatchedLines: 4, ScannedLines: 5, ScannedBytes: int64(len(content))},
+			// every line matches, so the timestamps are those of the first and last lines.
+			wantFirst: "2024-02-20T07:10:40Z",
+			wantLast:  "2024-02-20T07:10:42Z",
+		},
+		{
+			name:      "filtered",
+			body:      `{"path": "%s", "mode": "count", "match_substrings": ["error"]}`,
+			want:      handlers.CountResponse{MatchedLines: 3, ScannedLines: 5, ScannedBytes: int64(len(content))},
+			wantFirst: "2024-02-20T07:10:41Z",
+			wantLast:  "2024-02-20T07:10:42Z",
+		},
+		{
+			name:      "filter tree",
+			body:      `{"path": "%s", "mode": "count", "filter": {"and": [{"regex": "^2024"}, {"substring": "slow"}]}}`,
+			want:      handlers.CountResponse{MatchedLines: 1, ScannedLines: 5, ScannedBytes: int64(len(content))},
+			wantFirst: "2024-02-20T07:10:42Z",
+			wantLast:  "2024-02-20T07:10:42Z",
+		},
+		{
+			name: "no matches",
+			body: `{"path": "%s", "mode": "count", "match_substrings": ["warn"]}`,
+			want: handlers.CountResponse{MatchedLines: 0, ScannedLines: 5, ScannedBytes: int64(len(content))},
+		},
+		{
+			name: "range of lines",
+			body: `{"path": "%s", "mode": "count", "range": {"unit": "lines", "start": 3, "end": 5}}`,
+			want: handlers.CountResponse{
+				MatchedLines: 2,
+				ScannedLines: 2,
+				ScannedBytes: int64(len(lines[2]) + len(lines[3])),
+			},
+			wantFirst: "2024-02-20T07:10:41Z",
+			wantLast:  "2024-02-20T07:10:41Z",
+		},
+		{
+			name: "filtered range of bytes",
+			body: `{"path": "%s", "mode": "count", "range": {"start": ` + strconv.Itoa(len(lines[0])+len(lines[1])) + `}, ` +
+				`"match_substrings": ["disk"]}`,
+			want: handlers.CountResponse{
+				MatchedLines: 2,
+				ScannedLines: 3,
+				ScannedBytes: int64(len(content) - len(lines[0]) - len(lines[1])),
+			},
+			wantFirst: "2024-02-20T07:10:41Z",
+			wantLast:  "2024-02-20T07:10:42Z",
+		},
+		{
+			// num_lines doesn't limit counts.
+			name:      "num_lines",
+			body:      `{"path": "%s", "mode": "count", "num_lines": 1, "match_substrings": ["error"]}`,
+			want:      handlers.CountResponse{MatchedLines: 3, ScannedLines: 5, ScannedBytes: int64(len(content))},
+			wantFirst: "2024-02-20T07:10:41Z",
+			wantLast:  "2024-02-20T07:10:42Z",
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "count.log", content)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, filepath.Dir(path), strings.ReplaceAll(tc.body, "%s", path), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("unexpected content type - want: application/json, got: %s", got)
+			}
+			var got handlers.CountResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("bad count response - error: %s, body: %s", err, rec.Body)
+			}
+
+			if got.Host != handlers.FxtHost {
+				t.Errorf("unexpected host - want: %s, got: %s", handlers.FxtHost, got.Host)
+			}
+			if got.MatchedLines != tc.want.MatchedLines || got.ScannedLines != tc.want.ScannedLines ||
+				got.ScannedBytes != tc.want.ScannedBytes {
+				t.Errorf("unexpected counts - want: %d matched, %d scanned, %d bytes, got: %d, %d, %d",
+					tc.want.MatchedLines, tc.want.ScannedLines, tc.want.ScannedBytes, got.MatchedLines,
+					got.ScannedLines, got.ScannedBytes)
+			}
+			if first, last := formatTime(got.FirstTimestamp), formatTime(got.LastTimestamp); first != tc.wantFirst ||
+				last != tc.wantLast {
+				t.Errorf("unexpected timestamps - want: %q to %q, got: %q to %q", tc.wantFirst, tc.wantLast, first,
+					last)
+			}
+		})
+	}
+}
+
+// formatTime formats the time as RFC3339 in UTC, or returns an empty string if there isn't one.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
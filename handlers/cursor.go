@@ -0,0 +1,122 @@
+// Cursors for paging back through a log file for handlers.
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/marklap/cproject"
+)
+
+// errCursorFile is the error for a cursor of a file that is no longer at the requested path (e.g. it was rotated).
+var errCursorFile = errors.New("cursor is for a file that is no longer at the requested path")
+
+// cursor is a position in a log file to continue paging back from: the lines before `Offset` in the file identified
+// by `File` are the next page.
+type cursor struct {
+	File   cproject.FileID `json:"file"`
+	Offset int64           `json:"offset"`
+}
+
+// String encodes the cursor as an opaque string.
+func (c cursor) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseCursor decodes a cursor encoded by String.
+func parseCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.Offset <= 0 {
+		return c, fmt.Errorf("invalid cursor: %q", s)
+	}
+	return c, nil
+}
+
+// pager keeps track of the lines of a page to make the cursor of the next one.
+type pager struct {
+	file     cproject.FileID
+	numLines int
+	// matches are the number of lines of the page, not counting context lines.
+	matches int
+	// oldest is the offset of the oldest line of the page that isn't a context line, or -1 if the page is empty.
+	oldest int64
+}
+
+// newPager creates a new pager of pages of `numLines` lines of the file identified by `file`.
+func newPager(file cproject.FileID, numLines int) *pager {
+	return &pager{
+		file:     file,
+		numLines: numLines,
+		oldest:   -1,
+	}
+}
+
+// add adds a line of the page. Context lines are left out of the cursor: once the page is full, lines before its
+// oldest line that pass the filters are only returned as context, so the next page has to start with them.
+func (p *pager) add(line cproject.Line) {
+	if line.Kind != cproject.LineText {
+		return
+	}
+	p.matches++
+	if p.oldest < 0 || line.Offset < p.oldest {
+		p.oldest = line.Offset
+	}
+}
+
+// next returns the cursor of the next page. There isn't one if the page wasn't full, since reading reached the
+// beginning of the log file (or of its time range) before the page was.
+func (p *pager) next() (cursor, bool) {
+	if p.numLines <= 0 || p.matches < p.numLines || p.oldest <= 0 {
+		return cursor{}, false
+	}
+	return cursor{File: p.file, Offset: p.oldest}, true
+}
+
+// requestCursor returns the cursor a request continues paging back from, or nil if it doesn't have one. Only a single
+// log file can be paged through.
+func requestCursor(req *TailRequest, merge bool) (*cursor, error) {
+	if req.Cursor == "" && !req.IncludeCursor {
+		return nil, nil
+	}
+	if req.Follow {
+		return nil, errors.New("cursors can not be used with follow")
+	}
+	if merge || req.IncludeRotated {
+		return nil, errors.New("cursors can not be used with more than one log file")
+	}
+	if req.Cursor == "" {
+		return nil, nil
+	}
+	c, err := parseCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// newLogFilePager creates a pager of the log file being read, checking that it's the file `from` is a cursor of (if
+// there is one).
+func newLogFilePager(logFile cproject.LogFileReader, from *cursor, numLines int) (*pager, error) {
+	identifier, ok := logFile.(cproject.LogFileIdentifier)
+	if !ok {
+		return nil, fmt.Errorf("file can not be paged through: %s", logFile.Path())
+	}
+	id, err := identifier.ID()
+	if err != nil {
+		return nil, err
+	}
+	if from != nil && from.File != id {
+		return nil, errCursorFile
+	}
+	return newPager(id, numLines), nil
+}
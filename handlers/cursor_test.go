@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// decodeChunks decodes the chunks of an ndjson response.
+func decodeChunks(t *testing.T, body string) []handlers.TailResponseChunk {
+	t.Helper()
+	chunks := []handlers.TailResponseChunk{}
+	dec := json.NewDecoder(strings.NewReader(body))
+	for dec.More() {
+		var chunk handlers.TailResponseChunk
+		if err := dec.Decode(&chunk); err != nil {
+			t.Fatalf("bad chunk - error: %s, body: %s", err, body)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// readPage requests a page of the log file at `path` with the request `body`, continuing from `cursor` if it isn't
+// empty. It returns the lines of the page, with context lines in brackets, and the cursor of the next page.
+func readPage(t *testing.T, path, body, cursor string) ([]string, string) {
+	t.Helper()
+	body = strings.ReplaceAll(body, "%s", path)
+	if cursor != "" {
+		body = strings.TrimSuffix(body, "}") + `, "cursor": "` + cursor + `"}`
+	}
+	rec := tail(t, filepath.Dir(path), body, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	lines, next := []string{}, ""
+	for _, chunk := range decodeChunks(t, rec.Body.String()) {
+		switch {
+		case chunk.Event == "cursor":
+			next = chunk.Cursor
+		case chunk.Event != "":
+		case chunk.Kind == "context":
+			lines = append(lines, "["+chunk.Line+"]")
+		default:
+			lines = append(lines, chunk.Line)
+		}
+	}
+	return lines, next
+}
+
+func TestTailCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		body    string
+		// want are the lines of each page.
+		want [][]string
+	}{
+		{
+			name:    "pages",
+			content: "1\n2\n3\n4\n5\n6\n7\n",
+			body:    `{"path": "%s", "num_lines": 3, "include_cursor": true}`,
+			want:    [][]string{{"7", "6", "5"}, {"4", "3", "2"}, {"1"}},
+		},
+		{
+			name:    "full last page",
+			content: "1\n2\n3\n4\n",
+			body:    `{"path": "%s", "num_lines": 2, "include_cursor": true}`,
+			want:    [][]string{{"4", "3"}, {"2", "1"}},
+		},
+		{
+			name:    "filtered",
+			content: "match 1\nx\nmatch 2\nx\nx\nmatch 3\nmatch 4\nx\n",
+			body:    `{"path": "%s", "num_lines": 2, "include_cursor": true, "match_substrings": ["match"]}`,
+			want:    [][]string{{"match 4", "match 3"}, {"match 2", "match 1"}},
+		},
+		{
+			name:    "forward order",
+			content: "1\n2\n3\n4\n5\n",
+			body:    `{"path": "%s", "num_lines": 2, "include_cursor": true, "order": "forward"}`,
+			want:    [][]string{{"4", "5"}, {"2", "3"}, {"1"}},
+		},
+		{
+			name:    "after context",
+			content: "match 1\nx\nmatch 2\nx\nmatch 3\nx\n",
+			body: `{"path": "%s", "num_lines": 2, "include_cursor": true, "match_substrings": ["match"], ` +
+				`"after_context": 1}`,
+			want: [][]string{{"[x]", "match 3", "[x]", "match 2"}, {"[x]", "match 1"}},
+		},
+		{
+			// matches before the oldest match of a full page are context on that page and matches on the next.
+			name:    "before context",
+			content: "match 1\nmatch 2\nmatch 3\nx\nmatch 4\nmatch 5\n",
+			body: `{"path": "%s", "num_lines": 2, "include_cursor": true, "match_substrings": ["match"], ` +
+				`"before_context": 2}`,
+			want: [][]string{
+				{"match 5", "match 4", "[x]", "[match 3]"},
+				{"match 3", "match 2", "[match 1]"},
+				{"match 1"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "cursor.log", tc.content)
+			cursor := ""
+			for i, want := range tc.want {
+				var got []string
+				got, cursor = readPage(t, path, tc.body, cursor)
+				if strings.Join(got, "|") != strings.Join(want, "|") {
+					t.Errorf("unexpected page %d - want: %#v, got: %#v", i+1, want, got)
+				}
+				last := i == len(tc.want)-1
+				if last != (cursor == "") {
+					t.Fatalf("unexpected cursor on page %d: %q", i+1, cursor)
+				}
+			}
+		})
+	}
+}
+
+func TestTailCursorGone(t *testing.T) {
+	path := handlers.FxtLogFile(t, "rotated.log", "1\n2\n3\n")
+	body := `{"path": "%s", "num_lines": 1, "include_cursor": true}`
+	_, cursor := readPage(t, path, body, "")
+	if cursor == "" {
+		t.Fatal("no cursor on a full page")
+	}
+
+	// the log file is rotated: another file is at its path.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("1\n2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body = strings.ReplaceAll(body, "%s", path)
+	rec := tail(t, filepath.Dir(path), strings.TrimSuffix(body, "}")+`, "cursor": "`+cursor+`"}`, nil)
+	if rec.Code != http.StatusGone {
+		t.Errorf("unexpected status - want: %d, got: %d", http.StatusGone, rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != handlers.CodeCursorGone {
+		t.Errorf("unexpected code - want: %s, got: %s", handlers.CodeCursorGone, apiErr.Code)
+	}
+}
@@ -0,0 +1,272 @@
+// Output formats of tail responses for handlers.
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Output is the format the chunks of a tail response are written in.
+type Output string
+
+const (
+	// OutputNDJSON writes each chunk as a line of JSON (the default).
+	OutputNDJSON Output = "ndjson"
+	// OutputJSON writes the chunks in a single JSON document (see TailResponseEnvelope).
+	OutputJSON Output = "json"
+	// OutputCSV writes each chunk as a CSV record, after a header record.
+	OutputCSV Output = "csv"
+	// OutputText writes the lines as they are in the log file.
+	OutputText Output = "text"
+)
+
+// outputTypes are the media types of the outputs.
+var outputTypes = map[Output]string{
+	OutputNDJSON: "application/x-ndjson",
+	OutputJSON:   "application/json",
+	OutputCSV:    "text/csv",
+	OutputText:   "text/plain",
+}
+
+// ContentType returns the content type of responses in the output.
+func (o Output) ContentType() string {
+	if o == OutputCSV || o == OutputText {
+		return outputTypes[o] + "; charset=utf-8"
+	}
+	return outputTypes[o]
+}
+
+// responseOutput returns the output a request asks for: the request's `output`, if it has one, or the output named by
+// the media type the `Accept` header prefers. Media types that aren't outputs, and wildcards, leave the default
+// output (ndjson).
+func responseOutput(req *TailRequest, accept string) (Output, error) {
+	if req.Output != "" {
+		output := Output(req.Output)
+		if _, ok := outputTypes[output]; !ok {
+			return output, fmt.Errorf("unknown output: %q", req.Output)
+		}
+		return output, nil
+	}
+
+	best, bestQ := OutputNDJSON, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		for output, outputType := range outputTypes {
+			if mediaType == outputType && q > bestQ {
+				best, bestQ = output, q
+			}
+		}
+	}
+	return best, nil
+}
+
+// Encoder writes the chunks of a tail response in an output format.
+type Encoder interface {
+	// Encode writes a chunk.
+	Encode(chunk *TailResponseChunk) error
+	// Close writes whatever ends the response, including the summary if the output has room for it, once all the
+	// chunks have been written.
+	Close(summary *TailSummary) error
+}
+
+// newEncoder creates an encoder that writes chunks to `w` in the provided output. CSV records have a column for each
+// of `fields`.
+func newEncoder(output Output, w io.Writer, host string, fields []string) Encoder {
+	switch output {
+	case OutputJSON:
+		return &jsonEncoder{w: w, host: host}
+	case OutputCSV:
+		return &csvEncoder{w: csv.NewWriter(w), fields: fields}
+	case OutputText:
+		return &textEncoder{w: w}
+	}
+	return &ndjsonEncoder{w: w, host: host}
+}
+
+// ndjsonEncoder writes each chunk as a line of JSON.
+type ndjsonEncoder struct {
+	w    io.Writer
+	host string
+}
+
+// Encode writes the chunk as a line of JSON.
+func (e *ndjsonEncoder) Encode(chunk *TailResponseChunk) error {
+	return WriteJSONCompact(e.w, chunk)
+}
+
+// Close writes the summary as a last line of JSON, a `summary` event.
+func (e *ndjsonEncoder) Close(summary *TailSummary) error {
+	return WriteJSONCompact(e.w, &TailResponseChunk{Host: e.host, Event: "summary", Summary: summary})
+}
+
+// TailResponseEnvelope is the response to a tail request with `json` output: the chunks of the response in a single
+// JSON document.
+type TailResponseEnvelope struct {
+	Host  string              `json:"host"`
+	Lines []TailResponseChunk `json:"lines"`
+	// Count is the number of chunks in Lines.
+	Count   int          `json:"count"`
+	Summary *TailSummary `json:"summary"`
+}
+
+// jsonEncoder writes the chunks as the lines of a TailResponseEnvelope, one at a time, so the response can be
+// streamed.
+type jsonEncoder struct {
+	w     io.Writer
+	host  string
+	count int
+}
+
+// begin writes the start of the envelope, up to the lines.
+func (e *jsonEncoder) begin() error {
+	host, err := json.Marshal(e.host)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, `{"host":%s,"lines":[`, host)
+	return err
+}
+
+// Encode writes the chunk as the next of the lines of the envelope.
+func (e *jsonEncoder) Encode(chunk *TailResponseChunk) error {
+	b, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if e.count == 0 {
+		err = e.begin()
+	} else {
+		_, err = io.WriteString(e.w, ",")
+	}
+	if err != nil {
+		return err
+	}
+	e.count++
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Close writes the end of the envelope, with the summary.
+func (e *jsonEncoder) Close(summary *TailSummary) error {
+	if e.count == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, `],"count":%d,"summary":%s}`+"\n", e.count, b)
+	return err
+}
+
+// csvColumns are the columns of the CSV records of chunks, before a column for each of the requested fields.
+var csvColumns = []string{"host", "source", "offset", "line_number", "kind", "event", "cursor", "line"}
+
+// csvEncoder writes each chunk as a CSV record. Each record is flushed as it's written so followed log files can be
+// streamed.
+type csvEncoder struct {
+	w      *csv.Writer
+	fields []string
+	// header is true once the header record has been written.
+	header bool
+}
+
+// writeHeader writes the header record if it hasn't been written yet.
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(append(append([]string{}, csvColumns...), e.fields...))
+}
+
+// csvValue formats a field value for a CSV record: strings as they are and anything else as JSON.
+func csvValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Encode writes the chunk as a CSV record.
+func (e *csvEncoder) Encode(chunk *TailResponseChunk) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	offset, lineNumber := "", ""
+	if chunk.Offset != nil {
+		offset = strconv.FormatInt(*chunk.Offset, 10)
+	}
+	if chunk.LineNumber > 0 {
+		lineNumber = strconv.FormatInt(chunk.LineNumber, 10)
+	}
+	record := []string{chunk.Host, chunk.Source, offset, lineNumber, chunk.Kind, chunk.Event, chunk.Cursor, chunk.Line}
+	for _, field := range e.fields {
+		value, ok := chunk.Fields[field]
+		if !ok {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, csvValue(value))
+	}
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Close writes the header record if no chunks were written. The summary is left to the trailers; a record of it
+// wouldn't fit the columns.
+func (e *csvEncoder) Close(summary *TailSummary) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// textEncoder writes the lines as they are in the log file, one per line, for piping to other tools. Separators
+// between groups of context lines are written as `--` (like `grep -C`); other events are left out.
+type textEncoder struct {
+	w io.Writer
+}
+
+// Encode writes the line of the chunk.
+func (e *textEncoder) Encode(chunk *TailResponseChunk) error {
+	switch chunk.Event {
+	case "":
+		_, err := io.WriteString(e.w, chunk.Line+"\n")
+		return err
+	case "separator":
+		_, err := io.WriteString(e.w, "--\n")
+		return err
+	}
+	return nil
+}
+
+// Close does nothing; the summary is left to the trailers so only lines of the log file are written.
+func (e *textEncoder) Close(summary *TailSummary) error {
+	return nil
+}
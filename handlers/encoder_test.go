@@ -0,0 +1,223 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// tail sends the tail request `body` to a tail handler serving log files in `dir`, with the headers, and returns the
+// response.
+func tail(t *testing.T, dir, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/tail", strings.NewReader(body))
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handlers.TailHandler(handlers.FxtLogger(), handlers.FxtHost, []string{dir}, time.Minute).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResponseOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		accept     string
+		wantType   string
+		wantStatus int
+	}{
+		{name: "default", wantType: "application/x-ndjson"},
+		{name: "accepted", accept: "application/json", wantType: "application/json"},
+		{name: "parameters", accept: "text/plain; charset=utf-8", wantType: "text/plain; charset=utf-8"},
+		{
+			name:     "highest q wins",
+			accept:   "text/csv;q=0.5, application/json;q=0.9, text/plain;q=0.1",
+			wantType: "application/json",
+		},
+		{name: "first of equal q wins", accept: "text/csv, application/json", wantType: "text/csv; charset=utf-8"},
+		{name: "q defaults to 1", accept: "application/json;q=0.9, text/csv", wantType: "text/csv; charset=utf-8"},
+		{name: "q=0 is not acceptable", accept: "application/json;q=0", wantType: "application/x-ndjson"},
+		{
+			name:     "q=0 loses to any q",
+			accept:   "application/json;q=0, text/plain;q=0.001",
+			wantType: "text/plain; charset=utf-8",
+		},
+		{name: "bad q is skipped", accept: "application/json;q=high, text/csv;q=0.1", wantType: "text/csv; charset=utf-8"},
+		{name: "any type", accept: "*/*", wantType: "application/x-ndjson"},
+		{name: "wildcards are ignored", accept: "text/*, application/json;q=0.2", wantType: "application/json"},
+		{name: "unknown type", accept: "text/html", wantType: "application/x-ndjson"},
+		{name: "output", output: "text", wantType: "text/plain; charset=utf-8"},
+		{
+			name:     "output overrides accept",
+			output:   "csv",
+			accept:   "application/json",
+			wantType: "text/csv; charset=utf-8",
+		},
+		{name: "unknown output", output: "xml", accept: "text/csv", wantStatus: http.StatusBadRequest},
+	}
+
+	path := handlers.FxtLogFile(t, "output.log", "one\ntwo\n")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"path": "` + path + `", "output": "` + tc.output + `"}`
+			rec := tail(t, filepath.Dir(path), body, http.Header{"Accept": {tc.accept}})
+
+			wantStatus := tc.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if rec.Code != wantStatus {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", wantStatus, rec.Code, rec.Body)
+			}
+			if tc.wantType == "" {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("unexpected content type - want: %s, got: %s", tc.wantType, got)
+			}
+		})
+	}
+}
+
+func TestCSVQuoting(t *testing.T) {
+	content := "plain\nwith,comma\nwith \"quotes\"\nwith a\n  continuation\n"
+	path := handlers.FxtLogFile(t, "quoting.log", content)
+	body := `{"path": "` + path + `", "output": "csv", "num_lines": 10, "order": "forward", ` +
+		`"records": {"indented_continuation": true}}`
+	rec := tail(t, filepath.Dir(path), body, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	wantRaw := []string{`,"with,comma"`, `,"with ""quotes"""`, ",\"with a\n  continuation\""}
+	for _, want := range wantRaw {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("line not quoted - want: %q, in: %q", want, rec.Body)
+		}
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"plain", "with,comma", `with "quotes"`, "with a\n  continuation"}
+	got := []string{}
+	for _, record := range records[1:] {
+		got = append(got, record[len(record)-1])
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected lines - want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestJSONEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		// corrupt adds a rotated log file that can't be decompressed.
+		corrupt    bool
+		body       string
+		wantLines  []string
+		wantStatus string
+		wantCode   handlers.ErrorCode
+	}{
+		{
+			name:       "lines",
+			body:       `{"path": "%s", "num_lines": 2, "order": "forward"}`,
+			wantLines:  []string{"two", "three"},
+			wantStatus: handlers.SummaryOK,
+		},
+		{
+			name:       "no lines",
+			body:       `{"path": "%s", "match_substrings": ["nothing"]}`,
+			wantLines:  []string{},
+			wantStatus: handlers.SummaryOK,
+		},
+		{
+			name:       "error after lines",
+			corrupt:    true,
+			body:       `{"path": "%s", "num_lines": 10, "include_rotated": true}`,
+			wantLines:  []string{"three", "two", "one"},
+			wantStatus: handlers.SummaryError,
+			wantCode:   handlers.CodeInternalError,
+		},
+		{
+			name:       "error without lines",
+			corrupt:    true,
+			body:       `{"path": "%s", "num_lines": 10, "include_rotated": true, "match_substrings": ["nothing"]}`,
+			wantLines:  []string{},
+			wantStatus: handlers.SummaryError,
+			wantCode:   handlers.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "envelope.log", "one\ntwo\nthree\n")
+			if tc.corrupt {
+				var buf bytes.Buffer
+				zw := gzip.NewWriter(&buf)
+				zw.Write([]byte("zero\n"))
+				zw.Close()
+				// the compressed stream ends partway through.
+				if err := os.WriteFile(path+".1.gz", buf.Bytes()[:buf.Len()-10], 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			body := strings.ReplaceAll(tc.body, "%s", path)
+			rec := tail(t, filepath.Dir(path), body, http.Header{"Accept": {"application/json"}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+
+			// the whole response is a single JSON document.
+			var envelope handlers.TailResponseEnvelope
+			dec := json.NewDecoder(rec.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&envelope); err != nil {
+				t.Fatalf("bad envelope - error: %s", err)
+			}
+			if dec.More() {
+				t.Error("unexpected content after the envelope")
+			}
+
+			got := []string{}
+			for _, chunk := range envelope.Lines {
+				got = append(got, chunk.Line)
+			}
+			if strings.Join(got, "|") != strings.Join(tc.wantLines, "|") || envelope.Count != len(tc.wantLines) {
+				t.Errorf("unexpected lines - want: %#v, got: %#v (count: %d)", tc.wantLines, got, envelope.Count)
+			}
+			if envelope.Host != handlers.FxtHost {
+				t.Errorf("unexpected host - want: %s, got: %s", handlers.FxtHost, envelope.Host)
+			}
+			if envelope.Summary == nil {
+				t.Fatal("no summary")
+			}
+			if envelope.Summary.Status != tc.wantStatus {
+				t.Errorf("unexpected summary status - want: %s, got: %s", tc.wantStatus, envelope.Summary.Status)
+			}
+			if tc.wantCode == "" {
+				if envelope.Summary.Error != nil {
+					t.Errorf("unexpected summary error: %#v", envelope.Summary.Error)
+				}
+				return
+			}
+			if envelope.Summary.Error == nil || envelope.Summary.Error.Code != tc.wantCode {
+				t.Errorf("unexpected summary error - want: %s, got: %#v", tc.wantCode, envelope.Summary.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Typed errors of responses for handlers.
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/marklap/cproject"
+)
+
+// ErrorCode is a stable code for what a request failed with, for clients to act on rather than the message.
+type ErrorCode string
+
+const (
+	// CodeInvalidRequest is the code of a request that can't be served as it is (bad JSON, unknown options, options
+	// that can't be combined).
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	// CodeInvalidFilter is the code of a request with filters that can't be compiled.
+	CodeInvalidFilter ErrorCode = "invalid_filter"
+	// CodePathNotAllowed is the code of a request for a path outside the server's path prefixes.
+	CodePathNotAllowed ErrorCode = "path_not_allowed"
+	// CodeOriginNotAllowed is the code of a websocket request from a web page of another origin than the server.
+	CodeOriginNotAllowed ErrorCode = "origin_not_allowed"
+	// CodeFileNotFound is the code of a request for a log file that doesn't exist.
+	CodeFileNotFound ErrorCode = "file_not_found"
+	// CodePermissionDenied is the code of a request for a log file the server isn't allowed to read.
+	CodePermissionDenied ErrorCode = "permission_denied"
+	// CodeCursorGone is the code of a request with a cursor of a log file that is no longer at its path.
+	CodeCursorGone ErrorCode = "cursor_gone"
+	// CodeMethodNotAllowed is the code of a request with a method the endpoint doesn't serve.
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	// CodeInternalError is the code of a request that failed on the server's side.
+	CodeInternalError ErrorCode = "internal_error"
+)
+
+// errorStatuses are the HTTP statuses of the error codes.
+var errorStatuses = map[ErrorCode]int{
+	CodeInvalidRequest:   http.StatusBadRequest,
+	CodeInvalidFilter:    http.StatusBadRequest,
+	CodePathNotAllowed:   http.StatusForbidden,
+	CodeOriginNotAllowed: http.StatusForbidden,
+	CodeFileNotFound:     http.StatusNotFound,
+	CodePermissionDenied: http.StatusForbidden,
+	CodeCursorGone:       http.StatusGone,
+	CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	CodeInternalError:    http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status of responses with the error code.
+func (c ErrorCode) Status() int {
+	if status, ok := errorStatuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// APIError is an error as it's reported to clients.
+type APIError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// RequestID is the ID of the request that failed (see RequestIDHeader), for finding it in the server's log.
+	RequestID string `json:"request_id,omitempty"`
+	// Details is what's known about the error beyond its code, or nil if nothing is.
+	Details *ErrorDetails `json:"details,omitempty"`
+}
+
+// ErrorDetails is what's known about an error beyond its code.
+type ErrorDetails struct {
+	// Position is the byte offset in the regular expression of an `invalid_filter` error where the problem was found.
+	Position int `json:"position"`
+}
+
+// codedError is an error along with the code it's reported with.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+// Error returns the message of the wrapped error.
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withCode returns the error with the code it's reported with.
+func withCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// errorCode returns the code an error is reported with: the code it was given (see withCode), the code of what
+// caused it if that's known, or `fallback`.
+func errorCode(err error, fallback ErrorCode) ErrorCode {
+	var coded *codedError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, errInvalidPath):
+		return CodePathNotAllowed
+	case errors.Is(err, errCursorFile):
+		return CodeCursorGone
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeFileNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return CodePermissionDenied
+	}
+	return fallback
+}
+
+// newAPIError creates the error reported to clients for an error of the request with the provided ID.
+func newAPIError(err error, fallback ErrorCode, requestID string) *APIError {
+	apiErr := &APIError{
+		Code:      errorCode(err, fallback),
+		Message:   err.Error(),
+		RequestID: requestID,
+	}
+	var rerr *cproject.RegexpError
+	if errors.As(err, &rerr) {
+		apiErr.Details = &ErrorDetails{Position: rerr.Position}
+	}
+	return apiErr
+}
+
+// RequestIDHeader is the header a client can send a request ID in; the ID of every request is returned in it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length of the longest request ID a client can send.
+const maxRequestIDLength = 128
+
+// validRequestID returns true if the request ID a client sent is short and printable enough to be logged and
+// returned.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// setRequestID gives the request an ID, the one the client sent or a random one, and sets it on the response. Errors
+// written to the response are reported with it.
+func setRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+// requestLogger returns a logger that starts the messages it logs with the request ID.
+func requestLogger(logger *log.Logger, requestID string) *log.Logger {
+	return log.New(logger.Writer(), logger.Prefix()+"["+requestID+"] ", logger.Flags()|log.Lmsgprefix)
+}
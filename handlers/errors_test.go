@@ -0,0 +1,284 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// decodeError decodes the error of an error response, failing the test if the response isn't one.
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) *handlers.APIError {
+	t.Helper()
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad error response - error: %s, body: %s", err, rec.Body)
+	}
+	if resp.Error == nil {
+		t.Fatalf("no error in error response: %s", rec.Body)
+	}
+	return resp.Error
+}
+
+func TestErrorCodeStatus(t *testing.T) {
+	tests := []struct {
+		code handlers.ErrorCode
+		want int
+	}{
+		{code: handlers.CodeInvalidRequest, want: http.StatusBadRequest},
+		{code: handlers.CodeInvalidFilter, want: http.StatusBadRequest},
+		{code: handlers.CodePathNotAllowed, want: http.StatusForbidden},
+		{code: handlers.CodeOriginNotAllowed, want: http.StatusForbidden},
+		{code: handlers.CodeFileNotFound, want: http.StatusNotFound},
+		{code: handlers.CodePermissionDenied, want: http.StatusForbidden},
+		{code: handlers.CodeCursorGone, want: http.StatusGone},
+		{code: handlers.CodeMethodNotAllowed, want: http.StatusMethodNotAllowed},
+		{code: handlers.CodeInternalError, want: http.StatusInternalServerError},
+		{code: "unknown_code", want: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.code), func(t *testing.T) {
+			if got := tc.code.Status(); got != tc.want {
+				t.Errorf("unexpected status - want: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   handlers.ErrorCode
+		wantStatus int
+	}{
+		{
+			name:       "permission denied",
+			err:        &fs.PathError{Op: "open", Path: "/var/log/secure", Err: fs.ErrPermission},
+			wantCode:   handlers.CodePermissionDenied,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "permission denied errno",
+			err:        fmt.Errorf("opening log file: %w", &fs.PathError{Op: "open", Path: "x", Err: syscall.EACCES}),
+			wantCode:   handlers.CodePermissionDenied,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "not found",
+			err:        &fs.PathError{Op: "open", Path: "/var/log/missing.log", Err: fs.ErrNotExist},
+			wantCode:   handlers.CodeFileNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not found errno",
+			err:        fmt.Errorf("opening log file: %w", &fs.PathError{Op: "open", Path: "x", Err: syscall.ENOENT}),
+			wantCode:   handlers.CodeFileNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no code",
+			err:        errors.New("bad request"),
+			wantCode:   handlers.CodeInvalidRequest,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.Header().Set(handlers.RequestIDHeader, "req-1")
+			handlers.WriteJSONError(rec, tc.err)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("unexpected status - want: %d, got: %d", tc.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("unexpected content type - want: application/json, got: %s", got)
+			}
+			apiErr := decodeError(t, rec)
+			if apiErr.Code != tc.wantCode || apiErr.Message != tc.err.Error() || apiErr.RequestID != "req-1" {
+				t.Errorf("unexpected error - want: %s %q for req-1, got: %#v", tc.wantCode, tc.err, apiErr)
+			}
+		})
+	}
+}
+
+func TestTailErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantCode     handlers.ErrorCode
+		wantPosition int
+	}{
+		{
+			name:       "bad json",
+			body:       `{"path": `,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   handlers.CodeInvalidRequest,
+		},
+		{
+			name:       "path not allowed",
+			body:       `{"path": "/etc/passwd"}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   handlers.CodePathNotAllowed,
+		},
+		{
+			name:       "not found",
+			body:       `{"path": "%s/missing.log"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   handlers.CodeFileNotFound,
+		},
+		{
+			name:       "not found when counting",
+			body:       `{"path": "%s/missing.log", "mode": "count"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   handlers.CodeFileNotFound,
+		},
+		{
+			name:         "invalid regexp",
+			body:         `{"path": "%s/errors.log", "match_regex": "ab(c"}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     handlers.CodeInvalidFilter,
+			wantPosition: 2,
+		},
+		{
+			name:         "invalid regexp in a filter tree",
+			body:         `{"path": "%s/errors.log", "filter": {"or": [{"substring": "a"}, {"regex": "a[b"}]}}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     handlers.CodeInvalidFilter,
+			wantPosition: 1,
+		},
+		{
+			name:         "invalid records start_regex",
+			body:         `{"path": "%s/errors.log", "records": {"start_regex": "^(\\d+"}}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     handlers.CodeInvalidFilter,
+			wantPosition: 1,
+		},
+		{
+			name:       "invalid filter tree",
+			body:       `{"path": "%s/errors.log", "filter": {"and": []}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   handlers.CodeInvalidFilter,
+		},
+		{
+			name:       "unknown min_level",
+			body:       `{"path": "%s/errors.log", "min_level": "loud"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   handlers.CodeInvalidRequest,
+		},
+		{
+			name:       "unknown unparsed_lines",
+			body:       `{"path": "%s/errors.log", "fields": ["status"], "unparsed_lines": "keep"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   handlers.CodeInvalidRequest,
+		},
+		{
+			name:       "negative num_lines",
+			body:       `{"path": "%s/errors.log", "num_lines": -1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   handlers.CodeInvalidRequest,
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "errors.log", "one\n")
+	dir := filepath.Dir(path)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, dir, strings.ReplaceAll(tc.body, "%s", dir), nil)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("unexpected status - want: %d, got: %d", tc.wantStatus, rec.Code)
+			}
+			apiErr := decodeError(t, rec)
+			if apiErr.Code != tc.wantCode {
+				t.Errorf("unexpected code - want: %s, got: %s", tc.wantCode, apiErr.Code)
+			}
+			if apiErr.RequestID == "" || apiErr.RequestID != rec.Header().Get(handlers.RequestIDHeader) {
+				t.Errorf("unexpected request id - want: %q, got: %q", rec.Header().Get(handlers.RequestIDHeader),
+					apiErr.RequestID)
+			}
+
+			if tc.wantPosition == 0 {
+				if apiErr.Details != nil {
+					t.Errorf("unexpected details: %#v", apiErr.Details)
+				}
+				return
+			}
+			if apiErr.Details == nil || apiErr.Details.Position != tc.wantPosition {
+				t.Errorf("unexpected details - want position: %d, got: %#v", tc.wantPosition, apiErr.Details)
+			}
+		})
+	}
+}
+
+func TestTailPermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't apply to root")
+	}
+	path := handlers.FxtLogFile(t, "secret.log", "one\n")
+	if err := os.Chmod(path, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := tail(t, filepath.Dir(path), `{"path": "`+path+`"}`, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("unexpected status - want: %d, got: %d", http.StatusForbidden, rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != handlers.CodePermissionDenied {
+		t.Errorf("unexpected code - want: %s, got: %s", handlers.CodePermissionDenied, apiErr.Code)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{16}$`)
+	tests := []struct {
+		name string
+		id   string
+		// want is the request ID the response has, or empty if it has a generated one.
+		want string
+	}{
+		{name: "passed through", id: "client-id.42_ABC", want: "client-id.42_ABC"},
+		{name: "longest", id: strings.Repeat("a", 128), want: strings.Repeat("a", 128)},
+		{name: "punctuation", id: "!#$%&'*+-.^_`|~", want: "!#$%&'*+-.^_`|~"},
+		{name: "none"},
+		{name: "too long", id: strings.Repeat("a", 129)},
+		{name: "space", id: "client id"},
+		{name: "control character", id: "client\x01id"},
+		{name: "not ascii", id: "clïent"},
+	}
+
+	path := handlers.FxtLogFile(t, "ids.log", "one\n")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.id != "" {
+				header.Set(handlers.RequestIDHeader, tc.id)
+			}
+			// errors are reported with the request ID too.
+			rec := tail(t, filepath.Dir(path), `{"path": "`+path+`", "num_lines": -1}`, header)
+			got := rec.Header().Get(handlers.RequestIDHeader)
+			switch {
+			case tc.want != "" && got != tc.want:
+				t.Errorf("unexpected request id - want: %q, got: %q", tc.want, got)
+			case tc.want == "" && !generated.MatchString(got):
+				t.Errorf("unexpected request id - want a generated id, got: %q", got)
+			}
+			if apiErr := decodeError(t, rec); apiErr.RequestID != got {
+				t.Errorf("unexpected error request id - want: %q, got: %q", got, apiErr.RequestID)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Filter trees for handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/marklap/cproject"
+)
+
+// FilterSpec is a node of a filter tree in a tail request, for example:
+//
+//	{"and": [{"substring": "ERROR"}, {"not": {"substring": "healthcheck"}}]}
+//
+// Exactly one of `and`, `or`, `not`, `substring`, `regex` or `field` must be set on a node. A `field` node compares a
+// field of a structured line with `value` using `op`, for example:
+//
+//	{"field": "status", "op": ">=", "value": 500}
+type FilterSpec struct {
+	And           []FilterSpec `json:"and,omitempty"`
+	Or            []FilterSpec `json:"or,omitempty"`
+	Not           *FilterSpec  `json:"not,omitempty"`
+	Substring     string       `json:"substring,omitempty"`
+	Regex         string       `json:"regex,omitempty"`
+	CaseSensitive bool         `json:"case_sensitive,omitempty"`
+	Field         string       `json:"field,omitempty"`
+	Op            string       `json:"op,omitempty"`
+	Value         interface{}  `json:"value,omitempty"`
+}
+
+// String returns the filter tree as JSON.
+func (s *FilterSpec) String() string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// compileSpecs compiles each of the filter specs; `name` is where in the tree they are, for error messages. Field
+// nodes parse lines with `parser`.
+func compileSpecs(specs []FilterSpec, name string, parser cproject.LineParser) ([]cproject.Filter, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("%s: at least one filter is required", name)
+	}
+
+	filters := make([]cproject.Filter, 0, len(specs))
+	for i := range specs {
+		filter, err := specs[i].compile(fmt.Sprintf("%s[%d]", name, i), parser)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// compile compiles the filter tree rooted at this node; `name` is where in the tree the node is, for error messages.
+// Field nodes parse lines with `parser`.
+func (s *FilterSpec) compile(name string, parser cproject.LineParser) (cproject.Filter, error) {
+	set := 0
+	for _, isSet := range []bool{s.And != nil, s.Or != nil, s.Not != nil, s.Substring != "", s.Regex != "",
+		s.Field != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("%s: exactly one of and, or, not, substring, regex or field must be set", name)
+	}
+
+	switch {
+	case s.And != nil:
+		filters, err := compileSpecs(s.And, name+".and", parser)
+		if err != nil {
+			return nil, err
+		}
+		return cproject.All(filters), nil
+	case s.Or != nil:
+		filters, err := compileSpecs(s.Or, name+".or", parser)
+		if err != nil {
+			return nil, err
+		}
+		return cproject.Any(filters), nil
+	case s.Not != nil:
+		filter, err := s.Not.compile(name+".not", parser)
+		if err != nil {
+			return nil, err
+		}
+		return cproject.Not{Filter: filter}, nil
+	case s.Regex != "":
+		filter, err := cproject.NewMatchRegexp(s.Regex)
+		if err != nil {
+			return nil, err
+		}
+		return filter, nil
+	case s.Field != "":
+		filter, err := cproject.NewMatchField(parser, cproject.FieldPredicate{
+			Field: s.Field,
+			Op:    cproject.FieldOp(s.Op),
+			Value: s.Value,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return filter, nil
+	}
+	return cproject.NewMatchAnySubstring(
+		cproject.WithSubstrings([]string{s.Substring}),
+		cproject.WithCaseSensitivity(s.CaseSensitive),
+	), nil
+}
+
+// Compile compiles the filter tree rooted at this node into a filter. Field nodes parse lines with `parser`.
+func (s *FilterSpec) Compile(parser cproject.LineParser) (cproject.Filter, error) {
+	return s.compile("filter", parser)
+}
+
+// hasFields returns true if the filter tree rooted at this node has field nodes.
+func (s *FilterSpec) hasFields() bool {
+	if s == nil {
+		return false
+	}
+	if s.Field != "" || s.Not.hasFields() {
+		return true
+	}
+	for _, specs := range [][]FilterSpec{s.And, s.Or} {
+		for i := range specs {
+			if specs[i].hasFields() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UnparsedLines is what's done with lines that can't be parsed when a request uses the fields of structured lines.
+type UnparsedLines string
+
+const (
+	// UnparsedSkip leaves out lines that can't be parsed (the default).
+	UnparsedSkip UnparsedLines = "skip"
+	// UnparsedPass passes lines that can't be parsed through unfiltered.
+	UnparsedPass UnparsedLines = "pass"
+)
+
+// requestFilter builds the filter for a tail request. Lines matching any of `match_substrings` or `match_regex` are
+// included, narrowed by the `filter` tree and `min_level` when there are any. When the request uses the fields of
+// structured lines, lines are parsed with `parser` and lines that can't be parsed are skipped or passed through
+// unfiltered as the request asks. A nil filter is returned if the request doesn't filter lines. Regular expressions
+// and filter trees that can't be compiled are reported as invalid filters.
+func requestFilter(req *TailRequest, parser cproject.LineParser) (cproject.Filter, error) {
+	unparsed := UnparsedLines(req.UnparsedLines)
+	switch unparsed {
+	case "":
+		unparsed = UnparsedSkip
+	case UnparsedSkip, UnparsedPass:
+	default:
+		return nil, fmt.Errorf("unknown unparsed_lines: %q", req.UnparsedLines)
+	}
+
+	matchers := cproject.Any{}
+	if len(req.MatchSubstrings) > 0 {
+		matchers = append(matchers,
+			cproject.NewMatchAnySubstring(
+				cproject.WithSubstrings(req.MatchSubstrings),
+				cproject.WithCaseSensitivity(req.CaseSensitive)),
+		)
+	}
+	if req.MatchRegex != "" {
+		filter, err := cproject.NewMatchRegexp(req.MatchRegex)
+		if err != nil {
+			return nil, withCode(CodeInvalidFilter, err)
+		}
+		matchers = append(matchers, filter)
+	}
+
+	filters := cproject.All{}
+	if len(matchers) > 0 {
+		filters = append(filters, matchers)
+	}
+	if req.Filter != nil {
+		filter, err := req.Filter.Compile(parser)
+		if err != nil {
+			return nil, withCode(CodeInvalidFilter, err)
+		}
+		filters = append(filters, filter)
+	}
+	if req.MinLevel != "" {
+		level, err := cproject.ParseLevel(req.MinLevel)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, cproject.MinLevel{Level: level})
+	}
+
+	if len(req.Fields) > 0 || req.Filter.hasFields() {
+		if unparsed == UnparsedSkip {
+			filters = append(cproject.All{cproject.Parsed{Parser: parser}}, filters...)
+		} else if len(filters) > 0 {
+			return cproject.Any{cproject.Not{Filter: cproject.Parsed{Parser: parser}}, filters}, nil
+		}
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return filters[0], nil
+	}
+	return filters, nil
+}
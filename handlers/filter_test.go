@@ -0,0 +1,255 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject"
+	"github.com/marklap/cproject/handlers"
+)
+
+// compileSpec decodes the filter tree `spec` and compiles it, parsing lines as JSON.
+func compileSpec(spec string) (cproject.Filter, error) {
+	var s handlers.FilterSpec
+	if err := json.Unmarshal([]byte(spec), &s); err != nil {
+		return nil, err
+	}
+	return s.Compile(cproject.JSONParser{})
+}
+
+func TestFilterSpec(t *testing.T) {
+	lines := []string{
+		"ERROR disk full",
+		"error healthcheck failed",
+		"WARN disk slow",
+		"INFO started",
+		`{"status": 503, "msg": "unavailable"}`,
+		`{"status": 200, "msg": "ok"}`,
+	}
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{
+			name: "substring ignores case",
+			spec: `{"substring": "error"}`,
+			want: []string{"ERROR disk full", "error healthcheck failed"},
+		},
+		{
+			name: "substring case sensitive",
+			spec: `{"substring": "error", "case_sensitive": true}`,
+			want: []string{"error healthcheck failed"},
+		},
+		{
+			name: "regex",
+			spec: `{"regex": "^[A-Z]+ disk"}`,
+			want: []string{"ERROR disk full", "WARN disk slow"},
+		},
+		{
+			name: "and with not",
+			spec: `{"and": [{"substring": "error"}, {"not": {"substring": "healthcheck"}}]}`,
+			want: []string{"ERROR disk full"},
+		},
+		{
+			name: "or",
+			spec: `{"or": [{"substring": "WARN"}, {"regex": "^INFO"}]}`,
+			want: []string{"WARN disk slow", "INFO started"},
+		},
+		{
+			name: "nested",
+			spec: `{"or": [{"and": [{"substring": "disk"}, {"not": {"or": [{"substring": "WARN"}, ` +
+				`{"substring": "full"}]}}]}, {"substring": "started"}]}`,
+			want: []string{"INFO started"},
+		},
+		{
+			name: "field",
+			spec: `{"field": "status", "op": ">=", "value": 500}`,
+			want: []string{`{"status": 503, "msg": "unavailable"}`},
+		},
+		{
+			name: "field or substring",
+			spec: `{"or": [{"field": "msg", "op": "==", "value": "ok"}, {"substring": "WARN"}]}`,
+			want: []string{"WARN disk slow", `{"status": 200, "msg": "ok"}`},
+		},
+		{
+			name: "not field",
+			spec: `{"and": [{"regex": "^{"}, {"not": {"field": "status", "op": "<", "value": 500}}]}`,
+			want: []string{`{"status": 503, "msg": "unavailable"}`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := compileSpec(tc.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := []string{}
+			for _, line := range lines {
+				if filter.Include(line) {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterSpecInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "empty", spec: `{}`, wantErr: "filter: exactly one of"},
+		{name: "two kinds", spec: `{"substring": "a", "regex": "b"}`, wantErr: "filter: exactly one of"},
+		{name: "empty and", spec: `{"and": []}`, wantErr: "filter.and: at least one filter is required"},
+		{
+			name:    "nested empty node",
+			spec:    `{"and": [{"substring": "a"}, {"or": [{"substring": "b"}, {}]}]}`,
+			wantErr: "filter.and[1].or[1]: exactly one of",
+		},
+		{
+			name:    "nested two kinds",
+			spec:    `{"not": {"not": {"substring": "a", "or": [{"substring": "b"}]}}}`,
+			wantErr: "filter.not.not: exactly one of",
+		},
+		{name: "bad regex", spec: `{"or": [{"regex": "a(b"}]}`, wantErr: "missing closing )"},
+		{
+			name:    "bad field op",
+			spec:    `{"and": [{"field": "status", "op": "~", "value": 1}]}`,
+			wantErr: "filter.and[0]: unknown field operator",
+		},
+		{name: "and not a list", spec: `{"and": {"substring": "a"}}`, wantErr: "cannot unmarshal object"},
+		{name: "not a node", spec: `{"not": "a"}`, wantErr: "cannot unmarshal string"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := compileSpec(tc.spec)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("unexpected error - want: %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTailFilter(t *testing.T) {
+	content := "ERROR disk full\nerror healthcheck failed\nWARN disk slow\nINFO started\n"
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "tree",
+			body: `{"path": "%s", "filter": {"and": [{"substring": "error"}, {"not": {"substring": "healthcheck"}}]}}`,
+			want: []string{"ERROR disk full"},
+		},
+		{
+			name: "tree narrows matches",
+			body: `{"path": "%s", "match_substrings": ["disk", "started"], "filter": {"not": {"regex": "^WARN"}}}`,
+			want: []string{"INFO started", "ERROR disk full"},
+		},
+		{
+			name: "tree narrows min_level",
+			body: `{"path": "%s", "min_level": "warn", "filter": {"or": [{"substring": "disk"}, {"substring": "started"}]}}`,
+			want: []string{"WARN disk slow", "ERROR disk full"},
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "filter.log", content)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, filepath.Dir(path), strings.ReplaceAll(tc.body, "%s", path), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			got := []string{}
+			for _, chunk := range decodeChunks(t, rec.Body.String()) {
+				if chunk.Event == "" {
+					got = append(got, chunk.Line)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTailUnparsedLines(t *testing.T) {
+	content := `{"status": 200, "msg": "ok"}` + "\n" +
+		"plain text\n" +
+		`{"status": 503, "msg": "unavailable"}` + "\n" +
+		"{broken json\n"
+	tests := []struct {
+		name string
+		body string
+		// want are the lines returned, or their status field when they are returned with one.
+		want []string
+	}{
+		{
+			name: "skipped by default",
+			body: `{"path": "%s", "fields": ["status"]}`,
+			want: []string{"503", "200"},
+		},
+		{
+			name: "skipped",
+			body: `{"path": "%s", "fields": ["status"], "unparsed_lines": "skip"}`,
+			want: []string{"503", "200"},
+		},
+		{
+			name: "passed",
+			body: `{"path": "%s", "fields": ["status"], "unparsed_lines": "pass"}`,
+			want: []string{"{broken json", "503", "plain text", "200"},
+		},
+		{
+			name: "skipped by a field filter",
+			body: `{"path": "%s", "filter": {"field": "status", "op": ">=", "value": 500}, "unparsed_lines": "skip"}`,
+			want: []string{`{"status": 503, "msg": "unavailable"}`},
+		},
+		{
+			// unparsed lines are passed through even though they don't match the field filter.
+			name: "passed by a field filter",
+			body: `{"path": "%s", "filter": {"field": "status", "op": ">=", "value": 500}, "unparsed_lines": "pass"}`,
+			want: []string{"{broken json", `{"status": 503, "msg": "unavailable"}`, "plain text"},
+		},
+		{
+			// without fields, nothing is parsed, so nothing is skipped.
+			name: "no fields",
+			body: `{"path": "%s", "match_substrings": ["t"], "unparsed_lines": "skip"}`,
+			want: []string{`{"status": 503, "msg": "unavailable"}`, "plain text", `{"status": 200, "msg": "ok"}`},
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "unparsed.log", content)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, filepath.Dir(path), strings.ReplaceAll(tc.body, "%s", path), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			got := []string{}
+			for _, chunk := range decodeChunks(t, rec.Body.String()) {
+				switch {
+				case chunk.Event != "":
+				case chunk.Fields["status"] != nil:
+					got = append(got, fmt.Sprint(chunk.Fields["status"]))
+				default:
+					got = append(got, chunk.Line)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error error `json:"error"`
+	Error *APIError `json:"error"`
 }
 
 // WriteJSONWithIndent writes a value to the writer as JSON with a specific indent setting.
@@ -29,10 +29,30 @@ func WriteJSON(w io.Writer, v interface{}) error {
 	return WriteJSONWithIndent(w, v, "", "  ")
 }
 
-// WriteJSONErrorWithStatus writes the error with specific status.
-func WriteJSONErrorWithStatus(w http.ResponseWriter, err error, status int) {
+// writeJSONAPIError writes the error with the provided status. The error is reported with the request ID set on the
+// response, if there is one (see setRequestID).
+func writeJSONAPIError(w http.ResponseWriter, err error, fallback ErrorCode, status int) {
+	apiErr := newAPIError(err, fallback, w.Header().Get(RequestIDHeader))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	WriteJSON(w, &ErrorResponse{err})
+	WriteJSON(w, &ErrorResponse{apiErr})
+}
+
+// WriteJSONErrorWithStatus writes the error with specific status. The error is reported with its code (see
+// ErrorCode), or `internal_error` for server errors and `invalid_request` for others if it doesn't have one.
+func WriteJSONErrorWithStatus(w http.ResponseWriter, err error, status int) {
+	fallback := CodeInvalidRequest
+	if status >= http.StatusInternalServerError {
+		fallback = CodeInternalError
+	}
+	writeJSONAPIError(w, err, fallback, status)
+}
+
+// WriteJSONError writes the error to the writer as JSON with the response code of its code (see ErrorCode). Errors
+// without a code are reported as `invalid_request`.
+func WriteJSONError(w http.ResponseWriter, err error) {
+	code := errorCode(err, CodeInvalidRequest)
+	writeJSONAPIError(w, err, code, code.Status())
 }
 
 // WriteJSONServerError writes the error to the writer as JSON with an internal server error response code.
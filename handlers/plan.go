@@ -0,0 +1,162 @@
+// Validated tail requests for handlers.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// tailPlan is a validated tail request: what it reads and how the lines it reads are filtered and returned.
+type tailPlan struct {
+	req  TailRequest
+	mode ReadMode
+	// rng is the range to read for range reads (and counts of a range).
+	rng *cproject.Range
+	// merge is true when the log files in paths are merged.
+	merge    bool
+	paths    []string
+	numLines int
+	order    cproject.Order
+	since    time.Time
+	until    time.Time
+	// filter includes the lines the request asks for; a nil filter includes every line.
+	filter      cproject.Filter
+	proj        *projection
+	recordStart cproject.Filter
+	before      int
+	after       int
+	// from is the cursor the request continues paging back from, or nil if it doesn't have one.
+	from *cursor
+}
+
+// validateTailRequest validates a tail request, for log files under the path prefixes, and plans how it's read. Only
+// a single log file can be followed.
+func validateTailRequest(req *TailRequest, pathPrefixes []string) (*tailPlan, error) {
+	plan := &tailPlan{req: *req, merge: len(req.Paths) > 0 || req.Glob != ""}
+	var err error
+	switch {
+	case req.Follow && (plan.merge || req.IncludeRotated):
+		return nil, errors.New("only a single log file can be followed")
+	case plan.merge:
+		if plan.paths, err = mergePaths(req, pathPrefixes); err != nil {
+			return nil, err
+		}
+	case !validPrefix(req.Path, pathPrefixes):
+		return nil, fmt.Errorf("%w: %s", errInvalidPath, req.Path)
+	}
+
+	if plan.order, err = cproject.ParseOrder(req.Order); err != nil {
+		return nil, err
+	}
+	if plan.since, plan.until, err = timeRange(req); err != nil {
+		return nil, err
+	}
+	if plan.mode, plan.rng, err = readMode(req, plan.merge); err != nil {
+		return nil, err
+	}
+	if plan.numLines, err = requestNumLines(req, plan.mode); err != nil {
+		return nil, err
+	}
+
+	parser, err := cproject.ParseFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	if plan.filter, err = requestFilter(req, parser); err != nil {
+		return nil, err
+	}
+	plan.proj = &projection{parser: parser, fields: req.Fields}
+
+	// group lines into records if requested
+	if req.Records != nil {
+		if req.Follow {
+			return nil, errors.New("records can not be used with follow")
+		}
+		if plan.recordStart, err = req.Records.Compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	// read the lines around matching lines if requested
+	if plan.before, plan.after, err = lineContext(req, plan.merge); err != nil {
+		return nil, err
+	}
+
+	// page back from a cursor if requested
+	if plan.from, err = requestCursor(req, plan.merge); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// filters returns the filters the lines read for the plan pass through.
+func (p *tailPlan) filters() []cproject.Filter {
+	if p.filter == nil {
+		return []cproject.Filter{}
+	}
+	return []cproject.Filter{p.filter}
+}
+
+// open opens the log files the plan reads. A followed log file is followed from the offset `from` if it isn't
+// negative (see cproject.WithFollowFrom).
+func (p *tailPlan) open(from int64) (cproject.LogFileReader, error) {
+	withOrder, withTimeRange := cproject.WithOrder(p.order), cproject.WithTimeRange(p.since, p.until)
+	withRecordStart := cproject.WithRecordStart(p.recordStart)
+	withLineNumbers := cproject.WithLineNumbers(p.req.IncludePosition)
+	switch {
+	case p.merge:
+		return cproject.NewMergedLogFile(p.paths, withOrder, withTimeRange, withRecordStart, withLineNumbers)
+	case p.req.IncludeRotated:
+		return cproject.NewRotatedLogFile(p.req.Path, withOrder, withTimeRange, withRecordStart, withLineNumbers)
+	}
+
+	end := int64(-1)
+	if p.from != nil {
+		end = p.from.Offset
+	}
+	return cproject.OpenLogFile(p.req.Path, withOrder, withTimeRange, withRecordStart,
+		cproject.WithContext(p.before, p.after), withLineNumbers, cproject.WithEnd(end), cproject.WithFollowFrom(from))
+}
+
+// openFollower opens the log file the plan follows, from the offset `from` if it isn't negative.
+func (p *tailPlan) openFollower(from int64) (cproject.LogFileFollower, error) {
+	logFile, err := p.open(from)
+	if err != nil {
+		return nil, err
+	}
+	follower, ok := logFile.(cproject.LogFileFollower)
+	if !ok {
+		logFile.Close()
+		return nil, fmt.Errorf("file can not be followed: %s", p.req.Path)
+	}
+	return follower, nil
+}
+
+// chunk creates the response chunk of a line read for the plan. Markers (e.g. separators between context lines, or
+// rotation while following) are events; lines that pass the filters are marked as matches when context lines are
+// returned with them.
+func (p *tailPlan) chunk(host string, line cproject.Line) *TailResponseChunk {
+	chunk := &TailResponseChunk{
+		Host:   host,
+		Line:   line.Text,
+		Source: line.Source,
+	}
+	switch {
+	case line.Kind != cproject.LineText && line.Kind != cproject.LineContext:
+		chunk.Event = line.Kind.String()
+		return chunk
+	case p.before > 0 || p.after > 0:
+		chunk.Kind = "match"
+		if line.Kind == cproject.LineContext {
+			chunk.Kind = line.Kind.String()
+		}
+	}
+	chunk.Fields = p.proj.project(line.Text)
+	if p.req.IncludePosition {
+		chunk.setPosition(line)
+	}
+	return chunk
+}
@@ -0,0 +1,99 @@
+// Head and range reads for handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/marklap/cproject"
+)
+
+// ReadMode is what part of a log file a tail request reads.
+type ReadMode string
+
+const (
+	// ModeTail reads the last lines of a log file (the default).
+	ModeTail ReadMode = "tail"
+	// ModeHead reads the first lines of a log file.
+	ModeHead ReadMode = "head"
+	// ModeRange reads the lines of a range of a log file.
+	ModeRange ReadMode = "range"
+	// ModeCount counts the lines of a log file, or a range of it, instead of reading them.
+	ModeCount ReadMode = "count"
+)
+
+// RangeSpec describes a range of a log file to read in a tail request: the lines from `start` up to (not including)
+// `end`, by byte offset or line number.
+type RangeSpec struct {
+	// Unit is `bytes` (the default) or `lines`.
+	Unit  string `json:"unit,omitempty"`
+	Start int64  `json:"start"`
+	// End is the end of the range; 0 is the end of the log file.
+	End int64 `json:"end,omitempty"`
+}
+
+// String returns the range spec as JSON.
+func (s *RangeSpec) String() string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// Compile compiles the range spec into a range.
+func (s *RangeSpec) Compile() (cproject.Range, error) {
+	unit, err := cproject.ParseRangeUnit(s.Unit)
+	if err != nil {
+		return cproject.Range{}, err
+	}
+	rng := cproject.Range{Unit: unit, Start: s.Start, End: s.End}
+	if err := rng.Validate(); err != nil {
+		return cproject.Range{}, err
+	}
+	return rng, nil
+}
+
+// readMode returns what part of the log file a request reads, along with the range to read for range reads (and
+// counts of a range). Only tail reads can be followed, paged through or have context lines, and ranges can only be
+// read from a single log file.
+func readMode(req *TailRequest, merge bool) (ReadMode, *cproject.Range, error) {
+	mode := ReadMode(req.Mode)
+	switch mode {
+	case "":
+		mode = ModeTail
+	case ModeTail, ModeHead, ModeRange, ModeCount:
+	default:
+		return mode, nil, fmt.Errorf("unknown mode: %q", req.Mode)
+	}
+	if mode != ModeRange && mode != ModeCount && req.Range != nil {
+		return mode, nil, errors.New("range can only be used with mode range or count")
+	}
+	if mode == ModeTail {
+		return mode, nil, nil
+	}
+
+	switch {
+	case req.Follow:
+		return mode, nil, fmt.Errorf("mode %s can not be used with follow", mode)
+	case req.BeforeContext != 0 || req.AfterContext != 0:
+		return mode, nil, fmt.Errorf("mode %s can not be used with context lines", mode)
+	case req.Cursor != "" || req.IncludeCursor:
+		return mode, nil, fmt.Errorf("mode %s can not be used with cursors", mode)
+	case mode == ModeHead, mode == ModeCount && req.Range == nil:
+		return mode, nil, nil
+	case req.Range == nil:
+		return mode, nil, errors.New("mode range requires a range")
+	case merge || req.IncludeRotated:
+		return mode, nil, errors.New("ranges can not be read from more than one log file")
+	}
+	rng, err := req.Range.Compile()
+	if err != nil {
+		return mode, nil, err
+	}
+	return mode, &rng, nil
+}
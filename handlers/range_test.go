@@ -0,0 +1,174 @@
+package handlers_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+func TestTailMode(t *testing.T) {
+	// the lines start at offsets 0, 4, 8, 14 and 19.
+	content := "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "tail", body: `{"path": "%s", "num_lines": 2}`, want: []string{"five", "four"}},
+		{name: "explicit tail", body: `{"path": "%s", "mode": "tail", "num_lines": 2}`, want: []string{"five", "four"}},
+		{name: "head", body: `{"path": "%s", "mode": "head", "num_lines": 2}`, want: []string{"one", "two"}},
+		{
+			name: "head ignores order",
+			body: `{"path": "%s", "mode": "head", "num_lines": 2, "order": "reverse"}`,
+			want: []string{"one", "two"},
+		},
+		{
+			name: "range of bytes",
+			body: `{"path": "%s", "mode": "range", "range": {"start": 4, "end": 14}}`,
+			want: []string{"two", "three"},
+		},
+		{
+			// a line that starts before the start isn't read; one that starts before the end is read whole.
+			name: "range of bytes within lines",
+			body: `{"path": "%s", "mode": "range", "range": {"unit": "bytes", "start": 5, "end": 15}}`,
+			want: []string{"three", "four"},
+		},
+		{
+			name: "range to the end",
+			body: `{"path": "%s", "mode": "range", "range": {"start": 14}}`,
+			want: []string{"four", "five"},
+		},
+		{
+			name: "range of lines",
+			body: `{"path": "%s", "mode": "range", "range": {"unit": "lines", "start": 2, "end": 4}}`,
+			want: []string{"two", "three"},
+		},
+		{
+			name: "range limited by num_lines",
+			body: `{"path": "%s", "mode": "range", "range": {"unit": "lines", "start": 2}, "num_lines": 2}`,
+			want: []string{"two", "three"},
+		},
+		{
+			name: "range filtered",
+			body: `{"path": "%s", "mode": "range", "range": {"unit": "lines", "start": 2}, "match_substrings": ["f"]}`,
+			want: []string{"four", "five"},
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "mode.log", content)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, filepath.Dir(path), strings.ReplaceAll(tc.body, "%s", path), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			got := []string{}
+			for _, chunk := range decodeChunks(t, rec.Body.String()) {
+				if chunk.Event == "" {
+					got = append(got, chunk.Line)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTailModeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "unknown mode", body: `{"path": "%s", "mode": "middle"}`, wantErr: `unknown mode: "middle"`},
+		{
+			name:    "range without mode",
+			body:    `{"path": "%s", "range": {"start": 0}}`,
+			wantErr: "range can only be used with mode range or count",
+		},
+		{
+			name:    "range with head",
+			body:    `{"path": "%s", "mode": "head", "range": {"start": 0}}`,
+			wantErr: "range can only be used with mode range or count",
+		},
+		{name: "range mode without range", body: `{"path": "%s", "mode": "range"}`, wantErr: "mode range requires a range"},
+		{
+			name:    "head with follow",
+			body:    `{"path": "%s", "mode": "head", "follow": true}`,
+			wantErr: "mode head can not be used with follow",
+		},
+		{
+			name:    "range with follow",
+			body:    `{"path": "%s", "mode": "range", "range": {"start": 0}, "follow": true}`,
+			wantErr: "mode range can not be used with follow",
+		},
+		{
+			name:    "count with follow",
+			body:    `{"path": "%s", "mode": "count", "follow": true}`,
+			wantErr: "mode count can not be used with follow",
+		},
+		{
+			name:    "head with context",
+			body:    `{"path": "%s", "mode": "head", "before_context": 1}`,
+			wantErr: "mode head can not be used with context lines",
+		},
+		{
+			name:    "range with context",
+			body:    `{"path": "%s", "mode": "range", "range": {"start": 0}, "after_context": 1}`,
+			wantErr: "mode range can not be used with context lines",
+		},
+		{
+			name:    "head with cursor",
+			body:    `{"path": "%s", "mode": "head", "include_cursor": true}`,
+			wantErr: "mode head can not be used with cursors",
+		},
+		{
+			name:    "range with merge",
+			body:    `{"paths": ["%s", "%s"], "mode": "range", "range": {"start": 0}}`,
+			wantErr: "ranges can not be read from more than one log file",
+		},
+		{
+			name:    "range with rotated",
+			body:    `{"path": "%s", "mode": "range", "range": {"start": 0}, "include_rotated": true}`,
+			wantErr: "ranges can not be read from more than one log file",
+		},
+		{
+			name:    "count range with merge",
+			body:    `{"paths": ["%s", "%s"], "mode": "count", "range": {"start": 0}}`,
+			wantErr: "ranges can not be read from more than one log file",
+		},
+		{
+			name:    "unknown range unit",
+			body:    `{"path": "%s", "mode": "range", "range": {"unit": "pages", "start": 0}}`,
+			wantErr: `unknown range unit: "pages"`,
+		},
+		{
+			name:    "range end before start",
+			body:    `{"path": "%s", "mode": "range", "range": {"start": 10, "end": 5}}`,
+			wantErr: "range end (5) is before its start (10)",
+		},
+		{
+			name:    "range of lines from 0",
+			body:    `{"path": "%s", "mode": "range", "range": {"unit": "lines", "start": 0}}`,
+			wantErr: "range start (0) must be at least 1 for lines",
+		},
+	}
+
+	path := handlers.FxtLogFile(t, "mode.log", "one\n")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tail(t, filepath.Dir(path), strings.ReplaceAll(tc.body, "%s", path), nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("unexpected status - want: %d, got: %d", http.StatusBadRequest, rec.Code)
+			}
+			apiErr := decodeError(t, rec)
+			if apiErr.Code != handlers.CodeInvalidRequest || !strings.Contains(apiErr.Message, tc.wantErr) {
+				t.Errorf("unexpected error - want: %s %q, got: %#v", handlers.CodeInvalidRequest, tc.wantErr, apiErr)
+			}
+		})
+	}
+}
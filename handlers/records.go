@@ -0,0 +1,48 @@
+// Multi-line records for handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/marklap/cproject"
+)
+
+// RecordSpec describes how lines are grouped into multi-line records (e.g. a log line and the stack trace after it)
+// in a tail request. Exactly one of `start_regex` or `indented_continuation` must be set.
+type RecordSpec struct {
+	// StartRegex matches the lines that start a record; the lines after one that don't match continue its record.
+	StartRegex string `json:"start_regex,omitempty"`
+	// IndentedContinuation makes lines that start with whitespace continue the record of the line before them.
+	IndentedContinuation bool `json:"indented_continuation,omitempty"`
+}
+
+// String returns the record spec as JSON.
+func (s *RecordSpec) String() string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// Compile compiles the record spec into a filter that includes the lines that start a record. A `start_regex` that
+// can't be compiled is reported as an invalid filter.
+func (s *RecordSpec) Compile() (cproject.Filter, error) {
+	switch {
+	case s.StartRegex != "" && s.IndentedContinuation:
+		return nil, errors.New("records: only one of start_regex or indented_continuation can be set")
+	case s.StartRegex != "":
+		filter, err := cproject.NewMatchRegexp(s.StartRegex)
+		if err != nil {
+			return nil, withCode(CodeInvalidFilter, err)
+		}
+		return filter, nil
+	case s.IndentedContinuation:
+		return cproject.Unindented{}, nil
+	}
+	return nil, errors.New("records: one of start_regex or indented_continuation must be set")
+}
@@ -0,0 +1,226 @@
+// Server-sent event streams of followed log files for handlers.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// StreamHeartbeat is how often a comment is written to an idle event stream so proxies don't close the connection.
+var StreamHeartbeat = 15 * time.Second
+
+// streamRequest returns the tail request described by the query parameters of a stream request. The parameters are
+// named after the fields of TailRequest; `match_substrings` and `fields` can be repeated and `filter` is a filter tree
+// as JSON. Streamed log files are always followed.
+func streamRequest(query url.Values) (*TailRequest, error) {
+	req := &TailRequest{
+		Path:            query.Get("path"),
+		MatchSubstrings: query["match_substrings"],
+		MatchRegex:      query.Get("match_regex"),
+		Follow:          true,
+		Format:          query.Get("format"),
+		Fields:          query["fields"],
+		UnparsedLines:   query.Get("unparsed_lines"),
+		MinLevel:        query.Get("min_level"),
+	}
+
+	var err error
+	if s := query.Get("num_lines"); s != "" {
+		if req.NumLines, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("bad num_lines: %w", err)
+		}
+	}
+	if s := query.Get("case_sensitive"); s != "" {
+		if req.CaseSensitive, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("bad case_sensitive: %w", err)
+		}
+	}
+	if s := query.Get("include_position"); s != "" {
+		if req.IncludePosition, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("bad include_position: %w", err)
+		}
+	}
+	if s := query.Get("filter"); s != "" {
+		req.Filter = &FilterSpec{}
+		if err := json.Unmarshal([]byte(s), req.Filter); err != nil {
+			return nil, withCode(CodeInvalidFilter, fmt.Errorf("bad filter: %w", err))
+		}
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("bad since: %w", err)
+		}
+		req.Since = &since
+	}
+	return req, nil
+}
+
+// lastEventID returns the byte offset a stream resumes from: the ID of the last event the client received, from the
+// `Last-Event-ID` header an EventSource sends when it reconnects or the `last_event_id` query parameter. It returns -1
+// if the stream doesn't resume.
+func lastEventID(r *http.Request) (int64, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	if id == "" {
+		return -1, nil
+	}
+	offset, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || offset < 0 {
+		return -1, fmt.Errorf("bad last event id: %q", id)
+	}
+	return offset, nil
+}
+
+// writeEvent writes the chunk as the data of a server-sent event of the provided type, with the provided ID. An
+// empty event type is the default (`message`) type.
+func writeEvent(w io.Writer, event string, id int64, chunk *TailResponseChunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", id, data)
+	return err
+}
+
+// writeErrorEvent writes the error as the data of an `error` server-sent event. The event has no ID, so a stream that
+// resumes after it starts after the last line that was sent.
+func writeErrorEvent(w io.Writer, apiErr *APIError) error {
+	data, err := json.Marshal(&ErrorResponse{apiErr})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	return err
+}
+
+// streamEvents streams lines from the log file to the client as server-sent events, flushing each event as it's
+// written, until the client goes away or `maxFollow` has elapsed. The ID of each line event is the offset just past
+// the line, where a stream that resumes from it starts. Rotation and truncation markers are `rotated` and `truncated`
+// events with an ID of 0, the start of the new content. An error reading the log file is sent as an `error` event
+// before the stream ends. It returns the number of line bytes written.
+func streamEvents(w http.ResponseWriter, r *http.Request, logFile cproject.LogFileFollower, host string,
+	plan *tailPlan, maxFollow time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), maxFollow)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	// let the client know the stream is open before the first line arrives.
+	flush()
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	lineBytesOut := int64(0)
+	lines, errChan := logFile.Follow(ctx, plan.numLines, plan.filters()...)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				err := <-errChan
+				if err != nil {
+					writeErrorEvent(w, newAPIError(err, CodeInternalError, w.Header().Get(RequestIDHeader)))
+					flush()
+				}
+				return lineBytesOut, err
+			}
+			lineBytesOut += int64(len(line.Text))
+			chunk := plan.chunk(host, line)
+			event, id := "", line.Offset+int64(len(line.Text))+1
+			if chunk.Event != "" {
+				event, id = chunk.Event, 0
+			}
+			if err := writeEvent(w, event, id, chunk); err != nil {
+				return lineBytesOut, err
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return lineBytesOut, err
+			}
+		}
+		flush()
+	}
+}
+
+// StreamHandler handles `GET` requests to follow a log file as a stream of server-sent events, for browsers to read
+// with an EventSource. The request is made of query parameters (see streamRequest). A client that reconnects with the
+// ID of the last event it received resumes the stream after that line. Streams last no longer than `maxFollow`; an
+// EventSource reconnects, and resumes, when a stream ends.
+func StreamHandler(logger *log.Logger, host string, pathPrefixes []string, maxFollow time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := setRequestID(w, r)
+		logger := requestLogger(logger, requestID)
+
+		if r.Method != http.MethodGet {
+			err := withCode(CodeMethodNotAllowed, fmt.Errorf("stream requests must be %s requests", http.MethodGet))
+			logger.Printf("bad stream request - error: %s", err)
+			w.Header().Set("Allow", http.MethodGet)
+			WriteJSONError(w, err)
+			return
+		}
+
+		req, err := streamRequest(r.URL.Query())
+		if err != nil {
+			logger.Printf("bad stream request - error: %s", err)
+			WriteJSONError(w, err)
+			return
+		}
+		logger.Printf("stream request: %s", req.String())
+
+		// validation
+		plan, err := validateTailRequest(req, pathPrefixes)
+		if err != nil {
+			logger.Printf("bad stream request - error: %s", err)
+			WriteJSONError(w, err)
+			return
+		}
+
+		from, err := lastEventID(r)
+		if err != nil {
+			logger.Printf("bad stream request - error: %s", err)
+			WriteJSONBadRequest(w, err)
+			return
+		}
+
+		// create a log file value
+		follower, err := plan.openFollower(from)
+		if err != nil {
+			logger.Print(err)
+			WriteJSONError(w, err)
+			return
+		}
+		defer follower.Close()
+
+		// stream file
+		start := time.Now()
+		lineBytesOut, err := streamEvents(w, r, follower, host, plan, maxFollow)
+		if err != nil {
+			logger.Print(err)
+			return
+		}
+		logger.Printf("stream request - line bytes out written: %d [took %s]", lineBytesOut, time.Since(start))
+	})
+}
@@ -0,0 +1,284 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// sseEvent is a server-sent event, or a comment if `comment` is set.
+type sseEvent struct {
+	event   string
+	id      string
+	data    string
+	comment string
+}
+
+// openStream starts a stream of the log file at `path` with the query parameters and headers, failing the test if
+// the stream doesn't open. The stream is read from the returned reader.
+func openStream(t *testing.T, path string, query url.Values, header http.Header) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	server := httptest.NewServer(handlers.StreamHandler(handlers.FxtLogger(), handlers.FxtHost,
+		[]string{filepath.Dir(path)}, time.Minute))
+	t.Cleanup(server.Close)
+
+	query.Set("path", path)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status - want: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the next event (or comment) from the stream, failing the test if it doesn't arrive in time.
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	done := make(chan sseEvent, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		ev := sseEvent{}
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				errChan <- err
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				done <- ev
+				return
+			}
+			name, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch name {
+			case "":
+				ev.comment = value
+			case "event":
+				ev.event = value
+			case "id":
+				ev.id = value
+			case "data":
+				ev.data = value
+			}
+		}
+	}()
+	select {
+	case ev := <-done:
+		return ev
+	case err := <-errChan:
+		t.Fatalf("stream ended early - error: %s", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+// readLines reads line events from the stream, skipping comments, until `n` have been read. It returns the lines and
+// the IDs of their events.
+func readLines(t *testing.T, stream *bufio.Reader, n int) ([]string, []string) {
+	t.Helper()
+	lines, ids := []string{}, []string{}
+	for len(lines) < n {
+		ev := readEvent(t, stream)
+		if ev.comment != "" {
+			continue
+		}
+		if ev.event != "" {
+			t.Fatalf("unexpected %q event - data: %s", ev.event, ev.data)
+		}
+		var chunk handlers.TailResponseChunk
+		if err := json.Unmarshal([]byte(ev.data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		lines, ids = append(lines, chunk.Line), append(ids, ev.id)
+	}
+	return lines, ids
+}
+
+func TestStreamEventIDs(t *testing.T) {
+	content := "first line\nsecond line\n"
+	path := handlers.FxtLogFile(t, "stream.log", content)
+	_, stream := openStream(t, path, url.Values{"num_lines": {"2"}}, nil)
+
+	// the ID of each line is the offset just past it (and its newline).
+	wantLines, wantIDs := []string{"first line", "second line"}, []string{"11", "23"}
+	lines, ids := readLines(t, stream, 2)
+	if strings.Join(lines, "|") != strings.Join(wantLines, "|") {
+		t.Errorf("unexpected initial lines - want: %#v, got: %#v", wantLines, lines)
+	}
+	if strings.Join(ids, "|") != strings.Join(wantIDs, "|") {
+		t.Errorf("unexpected initial ids - want: %#v, got: %#v", wantIDs, ids)
+	}
+
+	// appended blank lines take up a byte of their own.
+	handlers.FxtAppend(t, path, "\nappended line\n")
+	wantLines = []string{"", "appended line"}
+	wantIDs = []string{strconv.Itoa(len(content) + 1), strconv.Itoa(len(content) + len("\nappended line\n"))}
+	lines, ids = readLines(t, stream, 2)
+	if strings.Join(lines, "|") != strings.Join(wantLines, "|") {
+		t.Errorf("unexpected appended lines - want: %#v, got: %#v", wantLines, lines)
+	}
+	if strings.Join(ids, "|") != strings.Join(wantIDs, "|") {
+		t.Errorf("unexpected appended ids - want: %#v, got: %#v", wantIDs, ids)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	content := "one\ntwo\nthree\n"
+	tests := []struct {
+		name   string
+		query  url.Values
+		header http.Header
+		want   []string
+	}{
+		{
+			name:   "header after first line",
+			header: http.Header{"Last-Event-ID": {"4"}},
+			want:   []string{"two", "three", "four"},
+		},
+		{
+			name:  "query after second line",
+			query: url.Values{"last_event_id": {"8"}},
+			want:  []string{"three", "four"},
+		},
+		{
+			name:   "header at the end",
+			header: http.Header{"Last-Event-ID": {strconv.Itoa(len(content))}},
+			want:   []string{"four"},
+		},
+		{
+			name:   "header wins over query",
+			query:  url.Values{"last_event_id": {"0"}},
+			header: http.Header{"Last-Event-ID": {"8"}},
+			want:   []string{"three", "four"},
+		},
+		{
+			name:   "resume ignores num_lines",
+			query:  url.Values{"num_lines": {"1"}},
+			header: http.Header{"Last-Event-ID": {"0"}},
+			want:   []string{"one", "two", "three", "four"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "resume.log", content)
+			query := tc.query
+			if query == nil {
+				query = url.Values{}
+			}
+			_, stream := openStream(t, path, query, tc.header)
+
+			// the appended line is the last one, so anything repeated or skipped shows up before it.
+			handlers.FxtAppend(t, path, "four\n")
+			got, _ := readLines(t, stream, len(tc.want))
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("unexpected lines - want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamResumeFromEventID(t *testing.T) {
+	path := handlers.FxtLogFile(t, "resume.log", "one\ntwo\n")
+	_, stream := openStream(t, path, url.Values{"num_lines": {"2"}}, nil)
+	_, ids := readLines(t, stream, 1)
+
+	// lines appended between the streams are resumed along with those not yet read.
+	handlers.FxtAppend(t, path, "three\n")
+	_, stream = openStream(t, path, url.Values{}, http.Header{"Last-Event-ID": {ids[0]}})
+	want := []string{"two", "three"}
+	if got, _ := readLines(t, stream, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected lines - want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestStreamResumeUnterminated(t *testing.T) {
+	content := "one\ntwo"
+	path := handlers.FxtLogFile(t, "unterminated.log", content)
+	_, stream := openStream(t, path, url.Values{"num_lines": {"2"}}, nil)
+	_, ids := readLines(t, stream, 2)
+
+	// the ID of a last line without a newline is where its newline would be.
+	if want := strconv.Itoa(len(content) + 1); ids[1] != want {
+		t.Fatalf("unexpected id - want: %s, got: %s", want, ids[1])
+	}
+
+	// resuming from it is neither a truncation nor a replay of the file.
+	_, stream = openStream(t, path, url.Values{}, http.Header{"Last-Event-ID": {ids[1]}})
+	handlers.FxtAppend(t, path, "\nthree\n")
+	want := []string{"three"}
+	if got, _ := readLines(t, stream, 1); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected lines - want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	heartbeat := handlers.StreamHeartbeat
+	handlers.StreamHeartbeat = 10 * time.Millisecond
+	t.Cleanup(func() { handlers.StreamHeartbeat = heartbeat })
+
+	path := handlers.FxtLogFile(t, "idle.log", "")
+	resp, stream := openStream(t, path, url.Values{}, nil)
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("unexpected content type - want: text/event-stream, got: %s", got)
+	}
+	for i := 0; i < 2; i++ {
+		if ev := readEvent(t, stream); ev.comment != "keepalive" {
+			t.Errorf("unexpected event on an idle stream - want: keepalive comment, got: %#v", ev)
+		}
+	}
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	path := handlers.FxtLogFile(t, filepath.Join("logs", "failing.log"), "")
+	dir := filepath.Dir(filepath.Dir(path))
+	_, stream := openStream(t, path, url.Values{}, http.Header{"X-Request-ID": {"req-1"}})
+
+	// a file where the directory of the log file was makes checking for rotation fail.
+	if err := os.Rename(filepath.Dir(path), filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Dir(path), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := readEvent(t, stream)
+	if ev.event != "error" {
+		t.Fatalf("unexpected event - want: error, got: %#v", ev)
+	}
+	if ev.id != "" {
+		t.Errorf("unexpected error event id - want none, got: %s", ev.id)
+	}
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal([]byte(ev.data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != handlers.CodeInternalError || resp.Error.RequestID != "req-1" {
+		t.Errorf("unexpected error - want: %s for req-1, got: %#v", handlers.CodeInternalError, resp.Error)
+	}
+	if _, err := stream.ReadByte(); err == nil {
+		t.Error("stream continued after the error event")
+	}
+}
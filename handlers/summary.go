@@ -0,0 +1,81 @@
+// Summaries that end tail responses for handlers.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+const (
+	// SummaryOK is the status of a tail response that was read to the end.
+	SummaryOK = "ok"
+	// SummaryError is the status of a tail response that stopped because reading failed.
+	SummaryError = "error"
+)
+
+// summaryTrailers are the names of the HTTP trailers a tail response's summary is sent in.
+var summaryTrailers = []string{
+	"X-Tail-Status", "X-Tail-Lines-Returned", "X-Tail-Bytes-Scanned", "X-Tail-Elapsed-Ms", "X-Tail-Error-Code",
+	"X-Tail-Error",
+}
+
+// TailSummary ends the response to a tail request, so clients can tell a response that has all the matching lines
+// from one that stopped because reading failed.
+type TailSummary struct {
+	Status        string    `json:"status"`
+	LinesReturned int64     `json:"lines_returned"`
+	BytesScanned  int64     `json:"bytes_scanned"`
+	ElapsedMs     float64   `json:"elapsed_ms"`
+	Error         *APIError `json:"error,omitempty"`
+}
+
+// newTailSummary creates the summary of a tail response that took since `start`, and stopped with `err` if it isn't
+// nil. The error is reported with the request ID.
+func newTailSummary(linesReturned, bytesScanned int64, start time.Time, err error, requestID string) *TailSummary {
+	summary := &TailSummary{
+		Status:        SummaryOK,
+		LinesReturned: linesReturned,
+		BytesScanned:  bytesScanned,
+		ElapsedMs:     float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		summary.Status, summary.Error = SummaryError, newAPIError(err, CodeInternalError, requestID)
+	}
+	return summary
+}
+
+// declareTrailers announces the trailers of the summary; it has to be called before the response is written.
+func declareTrailers(w http.ResponseWriter) {
+	w.Header().Set("Trailer", strings.Join(summaryTrailers, ", "))
+}
+
+// writeTrailers sets the trailers of the summary once the response has been written.
+func writeTrailers(w http.ResponseWriter, summary *TailSummary) {
+	w.Header().Set("X-Tail-Status", summary.Status)
+	w.Header().Set("X-Tail-Lines-Returned", strconv.FormatInt(summary.LinesReturned, 10))
+	w.Header().Set("X-Tail-Bytes-Scanned", strconv.FormatInt(summary.BytesScanned, 10))
+	w.Header().Set("X-Tail-Elapsed-Ms", strconv.FormatFloat(summary.ElapsedMs, 'f', 3, 64))
+	if summary.Error != nil {
+		w.Header().Set("X-Tail-Error-Code", string(summary.Error.Code))
+		w.Header().Set("X-Tail-Error", summary.Error.Message)
+	}
+}
+
+// scanCounter is a filter that counts the bytes of the lines (or records) it's asked about, newlines included, to
+// tell how much of the log files was scanned, before passing them to the filter it wraps. A nil filter includes every
+// line. Merged log files are read concurrently, so the count is atomic.
+type scanCounter struct {
+	filter cproject.Filter
+	bytes  atomic.Int64
+}
+
+// Include counts the line and returns true if the wrapped filter includes it.
+func (c *scanCounter) Include(line string) bool {
+	c.bytes.Add(int64(len(line)) + 1)
+	return c.filter == nil || c.filter.Include(line)
+}
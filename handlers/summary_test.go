@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+func TestTailSummary(t *testing.T) {
+	content := "one\nmatch two\nthree\nfour\n"
+	tests := []struct {
+		name   string
+		output string
+		body   string
+		// corrupt adds a rotated log file that can't be decompressed.
+		corrupt   bool
+		wantLines int64
+		wantBytes int64
+		wantCode  handlers.ErrorCode
+	}{
+		{name: "ndjson", output: "ndjson", body: `{"path": "%s", "num_lines": 2}`, wantLines: 2, wantBytes: 11},
+		{name: "json", output: "json", body: `{"path": "%s", "num_lines": 2}`, wantLines: 2, wantBytes: 11},
+		{name: "text", output: "text", body: `{"path": "%s", "num_lines": 2}`, wantLines: 2, wantBytes: 11},
+		{
+			name:      "filtered",
+			output:    "ndjson",
+			body:      `{"path": "%s", "match_substrings": ["match"]}`,
+			wantLines: 1,
+			wantBytes: int64(len(content)),
+		},
+		{
+			// the lines read as context are scanned too.
+			name:      "before context",
+			output:    "ndjson",
+			body:      `{"path": "%s", "num_lines": 1, "match_substrings": ["match"], "before_context": 1}`,
+			wantLines: 2,
+			wantBytes: int64(len(content)),
+		},
+		{
+			name:      "after context",
+			output:    "json",
+			body:      `{"path": "%s", "num_lines": 1, "match_substrings": ["match"], "after_context": 1}`,
+			wantLines: 2,
+			wantBytes: int64(len(content) - len("one\n")),
+		},
+		{
+			name:      "error ndjson",
+			output:    "ndjson",
+			body:      `{"path": "%s", "include_rotated": true}`,
+			corrupt:   true,
+			wantLines: 4,
+			// the line read from the rotated file before the error is scanned but not returned.
+			wantBytes: int64(len(content) + len("zero\n")),
+			wantCode:  handlers.CodeInternalError,
+		},
+		{
+			name:      "error text",
+			output:    "text",
+			body:      `{"path": "%s", "include_rotated": true}`,
+			corrupt:   true,
+			wantLines: 4,
+			// the line read from the rotated file before the error is scanned but not returned.
+			wantBytes: int64(len(content) + len("zero\n")),
+			wantCode:  handlers.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "summary.log", content)
+			if tc.corrupt {
+				var buf bytes.Buffer
+				zw := gzip.NewWriter(&buf)
+				zw.Write([]byte("zero\n"))
+				zw.Close()
+				if err := os.WriteFile(path+".1.gz", buf.Bytes()[:buf.Len()-4], 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			body := strings.TrimSuffix(strings.ReplaceAll(tc.body, "%s", path), "}") + `, "output": "` + tc.output + `"}`
+			rec := tail(t, filepath.Dir(path), body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+
+			// the summary is in the response, for outputs that have room for it, and in the trailers.
+			var summary *handlers.TailSummary
+			switch tc.output {
+			case "ndjson":
+				chunks := decodeChunks(t, rec.Body.String())
+				last := chunks[len(chunks)-1]
+				if last.Event != "summary" {
+					t.Fatalf("unexpected last chunk - want: summary event, got: %#v", last)
+				}
+				summary = last.Summary
+			case "json":
+				var envelope handlers.TailResponseEnvelope
+				if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+					t.Fatal(err)
+				}
+				summary = envelope.Summary
+			case "text":
+				if lines := strings.Count(rec.Body.String(), "\n"); int64(lines) != tc.wantLines {
+					t.Errorf("unexpected text - want: %d lines, got: %q", tc.wantLines, rec.Body)
+				}
+			}
+
+			wantStatus, wantCode := handlers.SummaryOK, ""
+			if tc.wantCode != "" {
+				wantStatus, wantCode = handlers.SummaryError, string(tc.wantCode)
+			}
+			if summary != nil {
+				gotCode := ""
+				if summary.Error != nil {
+					gotCode = string(summary.Error.Code)
+				}
+				if summary.Status != wantStatus || summary.LinesReturned != tc.wantLines ||
+					summary.BytesScanned != tc.wantBytes || gotCode != wantCode {
+					t.Errorf("unexpected summary - want: %s %d lines %d bytes %q, got: %#v", wantStatus, tc.wantLines,
+						tc.wantBytes, wantCode, summary)
+				}
+			} else if tc.output != "text" {
+				t.Error("no summary in the response")
+			}
+
+			trailer := rec.Result().Trailer
+			wantTrailer := map[string]string{
+				"X-Tail-Status":         wantStatus,
+				"X-Tail-Lines-Returned": strconv.FormatInt(tc.wantLines, 10),
+				"X-Tail-Bytes-Scanned":  strconv.FormatInt(tc.wantBytes, 10),
+				"X-Tail-Error-Code":     wantCode,
+			}
+			for name, want := range wantTrailer {
+				if got := trailer.Get(name); got != want {
+					t.Errorf("unexpected %s trailer - want: %q, got: %q", name, want, got)
+				}
+			}
+			if _, err := strconv.ParseFloat(trailer.Get("X-Tail-Elapsed-Ms"), 64); err != nil {
+				t.Errorf("bad X-Tail-Elapsed-Ms trailer - error: %s", err)
+			}
+			if (trailer.Get("X-Tail-Error") != "") != (wantCode != "") {
+				t.Errorf("unexpected X-Tail-Error trailer: %q", trailer.Get("X-Tail-Error"))
+			}
+		})
+	}
+}
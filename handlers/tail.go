@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,26 +17,153 @@ import (
 const (
 	// DefaultNumLines is the default number of lines to return if none specified.
 	DefaultNumLines = 10
+
+	// DefaultMaxFollow is the default maximum amount of time a followed file is streamed to a client.
+	DefaultMaxFollow = 5 * time.Minute
 )
 
 // TailRequest is a request to tail a file.
 type TailRequest struct {
-	Path            string   `json:"path"`
-	NumLines        int      `json:"num_lines"`
-	MatchSubstrings []string `json:"match_substrings"`
-	CaseSensitive   bool     `json:"case_sensitive"`
+	Path            string      `json:"path"`
+	NumLines        int         `json:"num_lines"`
+	MatchSubstrings []string    `json:"match_substrings"`
+	CaseSensitive   bool        `json:"case_sensitive"`
+	MatchRegex      string      `json:"match_regex"`
+	Filter          *FilterSpec `json:"filter"`
+	Order           string      `json:"order"`
+	Follow          bool        `json:"follow"`
+	IncludeRotated  bool        `json:"include_rotated"`
+	Paths           []string    `json:"paths"`
+	Glob            string      `json:"glob"`
+	Since           *time.Time  `json:"since"`
+	Until           *time.Time  `json:"until"`
+	Format          string      `json:"format"`
+	Fields          []string    `json:"fields"`
+	UnparsedLines   string      `json:"unparsed_lines"`
+	MinLevel        string      `json:"min_level"`
+	Records         *RecordSpec `json:"records"`
+	BeforeContext   int         `json:"before_context"`
+	AfterContext    int         `json:"after_context"`
+	IncludePosition bool        `json:"include_position"`
+	Cursor          string      `json:"cursor"`
+	IncludeCursor   bool        `json:"include_cursor"`
+	Mode            string      `json:"mode"`
+	Range           *RangeSpec  `json:"range"`
+	Output          string      `json:"output"`
 }
 
-// String pretty prints a tail request.
+// String returns the tail request as JSON.
 func (r *TailRequest) String() string {
-	return fmt.Sprintf("path: %s, num_lines: %d, match_substrings: %s, case_sensitive: %t",
-		r.Path, r.NumLines, r.MatchSubstrings, r.CaseSensitive)
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// formatTime formats an optional time in RFC3339 format.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// timeRange returns the time range a request asks for; a zero time leaves that end of the range open.
+func timeRange(req *TailRequest) (since, until time.Time, err error) {
+	if req.Since != nil {
+		since = *req.Since
+	}
+	if req.Until != nil {
+		until = *req.Until
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return since, until, fmt.Errorf("since (%s) is after until (%s)", formatTime(req.Since), formatTime(req.Until))
+	}
+	if req.Follow && !until.IsZero() {
+		return since, until, errors.New("until can not be used with follow")
+	}
+	return since, until, nil
+}
+
+// requestNumLines returns the number of lines a request asks for, or DefaultNumLines if it doesn't ask for a number.
+// Ranges are read in full unless a number is requested.
+func requestNumLines(req *TailRequest, mode ReadMode) (int, error) {
+	switch {
+	case req.NumLines < 0:
+		return 0, fmt.Errorf("num_lines (%d) can not be negative", req.NumLines)
+	case req.NumLines == 0 && mode != ModeRange:
+		return DefaultNumLines, nil
+	}
+	return req.NumLines, nil
+}
+
+// lineContext returns the number of lines before and after each matching line a request asks for.
+func lineContext(req *TailRequest, merge bool) (before, after int, err error) {
+	before, after = req.BeforeContext, req.AfterContext
+	switch {
+	case before < 0 || after < 0:
+		return before, after, fmt.Errorf("before_context (%d) and after_context (%d) can not be negative", before,
+			after)
+	case before == 0 && after == 0:
+		return before, after, nil
+	case req.Follow:
+		return before, after, errors.New("context lines can not be used with follow")
+	case merge || req.IncludeRotated:
+		return before, after, errors.New("context lines can not be used with more than one log file")
+	}
+	return before, after, nil
 }
 
 // TailResponseChunk is a response is a single line from a file.
 type TailResponseChunk struct {
-	Host string `json:"host"`
-	Line string `json:"line"`
+	Host       string          `json:"host"`
+	Line       string          `json:"line"`
+	Fields     cproject.Fields `json:"fields,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Source     string          `json:"source,omitempty"`
+	Offset     *int64          `json:"offset,omitempty"`
+	LineNumber int64           `json:"line_number,omitempty"`
+	Cursor     string          `json:"cursor,omitempty"`
+	Summary    *TailSummary    `json:"summary,omitempty"`
+}
+
+// MarshalJSON leaves the line out of marker chunks (events), which aren't lines of the log file.
+func (c TailResponseChunk) MarshalJSON() ([]byte, error) {
+	type chunk TailResponseChunk
+	if c.Event == "" {
+		return json.Marshal(chunk(c))
+	}
+	return json.Marshal(struct {
+		chunk
+		Line string `json:"line,omitempty"`
+	}{chunk: chunk(c)})
+}
+
+// setPosition sets the position of the line in the log file it was read from on the chunk.
+func (c *TailResponseChunk) setPosition(line cproject.Line) {
+	offset := line.Offset
+	c.Offset = &offset
+	c.LineNumber = line.Number
+}
+
+// projection projects the fields a request asks for out of the lines of a response.
+type projection struct {
+	parser cproject.LineParser
+	fields []string
+}
+
+// project returns the requested fields of the line, or nil if no fields were requested or the line can't be parsed.
+func (p *projection) project(line string) cproject.Fields {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	fields, ok := p.parser.Parse(line)
+	if !ok {
+		return nil
+	}
+	return fields.Project(p.fields)
 }
 
 func validPrefix(path string, pathPrefixes []string) bool {
@@ -47,9 +176,210 @@ func validPrefix(path string, pathPrefixes []string) bool {
 	return false
 }
 
-// TailHandler handles requests to tail a log file.
-func TailHandler(logger *log.Logger, host string, pathPrefixes []string) http.Handler {
+// followLines streams lines from the log file to the client with the encoder, flushing each chunk as it's written,
+// until the client goes away or `maxFollow` has elapsed. It returns the number of lines and line bytes written.
+func followLines(w http.ResponseWriter, r *http.Request, enc Encoder, logFile cproject.LogFileFollower, host string,
+	plan *tailPlan, filters []cproject.Filter, maxFollow time.Duration) (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), maxFollow)
+	defer cancel()
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		// send the headers straight away, rather than leaving the client waiting until a line is appended.
+		flusher.Flush()
+	}
+	linesOut, lineBytesOut := int64(0), int64(0)
+	lines, errChan := logFile.Follow(ctx, plan.numLines, filters...)
+	for line := range lines {
+		lineBytesOut += int64(len(line.Text))
+		chunk := plan.chunk(host, line)
+		if chunk.Event == "" {
+			linesOut++
+		}
+		enc.Encode(chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return linesOut, lineBytesOut, <-errChan
+}
+
+// errInvalidPath is the error for a path that doesn't have a valid prefix.
+var errInvalidPath = errors.New("invalid path")
+
+// mergePaths returns the paths of the log files a request asks to merge: `path` (if any), `paths` and the matches of
+// `glob`. Each path must have a valid prefix.
+func mergePaths(req *TailRequest, pathPrefixes []string) ([]string, error) {
+	paths := []string{}
+	if req.Path != "" {
+		paths = append(paths, req.Path)
+	}
+	paths = append(paths, req.Paths...)
+
+	if req.Glob != "" {
+		if !validPrefix(req.Glob, pathPrefixes) {
+			return nil, fmt.Errorf("%w: %s", errInvalidPath, req.Glob)
+		}
+		matches, err := filepath.Glob(req.Glob)
+		if err != nil {
+			return nil, fmt.Errorf("bad glob: %w", err)
+		}
+		paths = append(paths, matches...)
+	}
+
+	for _, path := range paths {
+		if !validPrefix(path, pathPrefixes) {
+			return nil, fmt.Errorf("%w: %s", errInvalidPath, path)
+		}
+	}
+	return paths, nil
+}
+
+// tailResponder writes the response to a validated tail request.
+type tailResponder struct {
+	w         http.ResponseWriter
+	r         *http.Request
+	logger    *log.Logger
+	host      string
+	requestID string
+	plan      *tailPlan
+	output    Output
+}
+
+// begin sets the headers of a response of lines, and the trailers of its summary, and returns the encoder its lines
+// are written with.
+func (t *tailResponder) begin() Encoder {
+	t.w.Header().Set("Content-Type", t.output.ContentType())
+	declareTrailers(t.w)
+	return newEncoder(t.output, t.w, t.host, t.plan.req.Fields)
+}
+
+// finish ends a response of lines with its summary; `err` is the error reading stopped with, if any.
+func (t *tailResponder) finish(enc Encoder, linesOut int64, scanned *scanCounter, start time.Time, err error) {
+	summary := newTailSummary(linesOut, scanned.bytes.Load(), start, err, t.requestID)
+	enc.Close(summary)
+	writeTrailers(t.w, summary)
+}
+
+// follow streams the last lines of the log file and then lines as they are appended to it, for no longer than
+// `maxFollow`.
+func (t *tailResponder) follow(maxFollow time.Duration) {
+	follower, err := t.plan.openFollower(-1)
+	if err != nil {
+		t.logger.Print(err)
+		WriteJSONError(t.w, err)
+		return
+	}
+	defer follower.Close()
+
+	start := time.Now()
+	scanned := &scanCounter{filter: t.plan.filter}
+	enc := t.begin()
+	linesOut, lineBytesOut, err := followLines(t.w, t.r, enc, follower, t.host, t.plan,
+		[]cproject.Filter{scanned}, maxFollow)
+	t.finish(enc, linesOut, scanned, start, err)
+	if err != nil {
+		t.logger.Print(err)
+		return
+	}
+	t.logger.Printf("follow request - line bytes out written: %d [took %s]", lineBytesOut, time.Since(start))
+}
+
+// count counts the lines of the log files, or of a range of them, that pass the filters.
+func (t *tailResponder) count() {
+	logFile, err := t.plan.open(-1)
+	if err != nil {
+		t.logger.Print(err)
+		WriteJSONError(t.w, err)
+		return
+	}
+	defer logFile.Close()
+
+	start := time.Now()
+	resp, err := countLines(t.r.Context(), logFile, t.host, t.plan.rng, t.plan.filters())
+	if err != nil {
+		t.logger.Print(err)
+		WriteJSONServerError(t.w, err)
+		return
+	}
+	t.w.Header().Set("Content-Type", OutputJSON.ContentType())
+	WriteJSON(t.w, resp)
+	t.logger.Printf("count request - matched lines: %d of %d [took %s]", resp.MatchedLines, resp.ScannedLines,
+		time.Since(start))
+}
+
+// read reads the lines of the log files the mode asks for: the last lines (a page of them, if the request pages
+// back with cursors), the first lines or the lines of a range.
+func (t *tailResponder) read() {
+	logFile, err := t.plan.open(-1)
+	if err != nil {
+		t.logger.Print(err)
+		WriteJSONError(t.w, err)
+		return
+	}
+	defer logFile.Close()
+
+	// keep track of the lines read to make the cursor of the next page if requested
+	var pages *pager
+	if t.plan.req.Cursor != "" || t.plan.req.IncludeCursor {
+		if pages, err = newLogFilePager(logFile, t.plan.from, t.plan.numLines); err != nil {
+			t.logger.Printf("bad tail request - error: %s", err)
+			WriteJSONError(t.w, err)
+			return
+		}
+	}
+
+	// every line read passes through the scan counter for the summary.
+	start := time.Now()
+	scanned := &scanCounter{filter: t.plan.filter}
+	filters := []cproject.Filter{scanned}
+	enc := t.begin()
+	var lines chan cproject.Line
+	var errChan chan error
+	switch t.plan.mode {
+	case ModeHead:
+		lines, errChan = logFile.HeadLines(t.r.Context(), t.plan.numLines, filters...)
+	case ModeRange:
+		lines, errChan = logFile.RangeLines(t.r.Context(), *t.plan.rng, t.plan.numLines, filters...)
+	default:
+		lines, errChan = logFile.YieldLinesContext(t.r.Context(), t.plan.numLines, filters...)
+	}
+	linesOut, lineBytesOut := int64(0), int64(0)
+	for line := range lines {
+		lineBytesOut += int64(len(line.Text))
+		if pages != nil {
+			pages.add(line)
+		}
+		chunk := t.plan.chunk(t.host, line)
+		if chunk.Event == "" {
+			linesOut++
+		}
+		enc.Encode(chunk)
+	}
+
+	// check for errors; the summary tells the client whether all the lines were read.
+	err = <-errChan
+	if err == nil && pages != nil {
+		if next, ok := pages.next(); ok {
+			enc.Encode(&TailResponseChunk{Host: t.host, Event: "cursor", Cursor: next.String()})
+		}
+	}
+	t.finish(enc, linesOut, scanned, start, err)
+	if err != nil {
+		t.logger.Print(err)
+		return
+	}
+	t.logger.Printf("%s request - line bytes out written: %d [took %s]", t.plan.mode, lineBytesOut,
+		time.Since(start))
+}
+
+// TailHandler handles requests to tail a log file. Followed files are streamed for no longer than `maxFollow`.
+func TailHandler(logger *log.Logger, host string, pathPrefixes []string, maxFollow time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := setRequestID(w, r)
+		logger := requestLogger(logger, requestID)
+
 		// decode the incoming request
 		decoder := json.NewDecoder(r.Body)
 		var req TailRequest
@@ -62,60 +392,25 @@ func TailHandler(logger *log.Logger, host string, pathPrefixes []string) http.Ha
 		logger.Printf("tail request: %s", req.String())
 
 		// validation
-		if !validPrefix(req.Path, pathPrefixes) {
-			logger.Printf("bad tail request - error: invalid path: %s", req.Path)
-			w.WriteHeader(http.StatusNotFound)
-			return
+		plan, err := validateTailRequest(&req, pathPrefixes)
+		var output Output
+		if err == nil {
+			output, err = responseOutput(&req, r.Header.Get("Accept"))
 		}
-
-		// create a log file value
-		var (
-			err     error
-			logFile cproject.LogFileReader
-		)
-		logFile, err = cproject.NewLogFile(req.Path)
 		if err != nil {
-			logger.Print(err)
-			WriteJSONBadRequest(w, err)
+			logger.Printf("bad tail request - error: %s", err)
+			WriteJSONError(w, err)
 			return
 		}
-		defer logFile.Close()
 
-		// determine num lines to return
-		numLines := req.NumLines
-		if numLines == 0 {
-			numLines = DefaultNumLines
-		}
-
-		// create filters if requested
-		filters := []cproject.Filter{}
-		if len(req.MatchSubstrings) > 0 {
-			filters = append(filters,
-				cproject.NewMatchAnySubstring(
-					cproject.WithSubstrings(req.MatchSubstrings),
-					cproject.WithCaseSensitivity(req.CaseSensitive)),
-			)
-		}
-
-		// tail file
-		start := time.Now()
-		lineBytesOut := int64(0)
-		lines, errChan := logFile.YieldLines(numLines, filters...)
-		for line := range lines {
-			lineBytesOut += int64(len([]byte(line)))
-			chunk := TailResponseChunk{
-				Host: host,
-				Line: line,
-			}
-			WriteJSONCompact(w, &chunk)
-		}
-
-		// check for errors
-		err = <-errChan
-		if err != nil {
-			logger.Print(err)
-			return
+		t := &tailResponder{w: w, r: r, logger: logger, host: host, requestID: requestID, plan: plan, output: output}
+		switch {
+		case req.Follow:
+			t.follow(maxFollow)
+		case plan.mode == ModeCount:
+			t.count()
+		default:
+			t.read()
 		}
-		logger.Printf("tail request - line bytes out written: %d [took %s]", lineBytesOut, time.Since(start))
 	})
 }
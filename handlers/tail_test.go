@@ -0,0 +1,137 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// readChunk reads the next chunk of an ndjson response, failing the test if it doesn't arrive in time. It returns
+// false if the response ended instead.
+func readChunk(t *testing.T, body *bufio.Reader) (handlers.TailResponseChunk, bool) {
+	t.Helper()
+	done := make(chan string, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			errChan <- err
+			return
+		}
+		done <- line
+	}()
+
+	var chunk handlers.TailResponseChunk
+	select {
+	case line := <-done:
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("bad chunk - error: %s, line: %s", err, line)
+		}
+		return chunk, true
+	case err := <-errChan:
+		if err != io.EOF {
+			t.Fatalf("response ended early - error: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a chunk")
+	}
+	return chunk, false
+}
+
+func TestTailFollow(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		body    string
+		// appended are appended to the log file one at a time; want are the lines each is expected to be streamed as.
+		appended []string
+		want     [][]string
+	}{
+		{
+			name:     "last lines then appended",
+			content:  "one\ntwo\n",
+			body:     `{"path": "%s", "num_lines": 1, "follow": true}`,
+			appended: []string{"three\n", "four\nfive\n"},
+			want:     [][]string{{"two"}, {"three"}, {"four", "five"}},
+		},
+		{
+			name:     "filtered",
+			content:  "match one\ntwo\n",
+			body:     `{"path": "%s", "num_lines": 1, "follow": true, "match_substrings": ["match"]}`,
+			appended: []string{"three\nmatch four\n", "match five\n"},
+			want:     [][]string{{"match one"}, {"match four"}, {"match five"}},
+		},
+		{
+			name:     "empty file",
+			body:     `{"path": "%s", "follow": true}`,
+			appended: []string{"one\n"},
+			want:     [][]string{{}, {"one"}},
+		},
+	}
+
+	const maxFollow = time.Second
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "follow.log", tc.content)
+			server := httptest.NewServer(handlers.TailHandler(handlers.FxtLogger(), handlers.FxtHost,
+				[]string{filepath.Dir(path)}, maxFollow))
+			t.Cleanup(server.Close)
+
+			start := time.Now()
+			resp, err := server.Client().Post(server.URL, "application/json",
+				strings.NewReader(strings.ReplaceAll(tc.body, "%s", path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status - want: %d, got: %d", http.StatusOK, resp.StatusCode)
+			}
+			body := bufio.NewReader(resp.Body)
+
+			// each line is flushed as it's read, so it arrives while the response is still open.
+			linesOut := 0
+			for i, want := range tc.want {
+				if i > 0 {
+					handlers.FxtAppend(t, path, tc.appended[i-1])
+				}
+				got := []string{}
+				for len(got) < len(want) {
+					chunk, ok := readChunk(t, body)
+					if !ok {
+						t.Fatalf("response ended before lines %#v", want)
+					}
+					got = append(got, chunk.Line)
+				}
+				if strings.Join(got, "|") != strings.Join(want, "|") {
+					t.Errorf("unexpected lines - want: %#v, got: %#v", want, got)
+				}
+				linesOut += len(want)
+			}
+
+			// following stops at the maximum follow duration, ending the response with its summary.
+			chunk, ok := readChunk(t, body)
+			if !ok || chunk.Event != "summary" || chunk.Summary == nil {
+				t.Fatalf("unexpected chunk - want: summary event, got: %#v", chunk)
+			}
+			if elapsed := time.Since(start); elapsed < maxFollow {
+				t.Errorf("following stopped early - want: at least %s, got: %s", maxFollow, elapsed)
+			}
+			if chunk.Summary.Status != handlers.SummaryOK || chunk.Summary.LinesReturned != int64(linesOut) {
+				t.Errorf("unexpected summary - want: %s %d lines, got: %#v", handlers.SummaryOK, linesOut,
+					chunk.Summary)
+			}
+			if _, ok := readChunk(t, body); ok {
+				t.Error("response continued after the summary")
+			}
+		})
+	}
+}
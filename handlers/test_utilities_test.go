@@ -0,0 +1,45 @@
+// Test utilities.
+package handlers
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FxtHost is the host handlers under test respond as.
+const FxtHost = "test.host:8080"
+
+// Fixture for a logger that discards what it logs.
+func FxtLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+// Creates a log file at the relative path `name` in a temporary directory with the contents of `content`. It returns
+// the path of the log file.
+func FxtLogFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Appends `content` to the log file at `path`.
+func FxtAppend(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,300 @@
+// Server side of the WebSocket protocol (RFC 6455) for handlers.
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the key of an opening handshake to make the accept key of the response.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// opcodes of websocket frames.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// close codes of websocket close frames.
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsClosePolicy        = 1008
+	wsCloseTooBig        = 1009
+	wsCloseInternalError = 1011
+)
+
+const (
+	// maxWebSocketMessage is the largest message a client can send.
+	maxWebSocketMessage = 1 << 20
+	// wsWriteTimeout is how long writing a frame can take before the client is taken to be gone.
+	wsWriteTimeout = 10 * time.Second
+	// wsCloseTimeout is how long the client has to answer a close frame before the connection is closed anyway.
+	wsCloseTimeout = 5 * time.Second
+)
+
+// errWebSocketClosed is the error for writing to a websocket connection after a close frame has been sent.
+var errWebSocketClosed = errors.New("websocket connection is closed")
+
+// wsCloseError is an error that closes a websocket connection with a close code and reason.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+// Error returns the close code and reason.
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket close %d: %s", e.code, e.reason)
+}
+
+// wsConn is the server side of a websocket connection. Messages are read by one goroutine at a time; frames can be
+// written from any goroutine.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// mu guards writes to the connection and closeSent.
+	mu        sync.Mutex
+	closeSent bool
+}
+
+// headerContains returns true if the comma separated list of tokens in the header has the token (ignoring case).
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin returns true if the origin of a request (the `Origin` header a browser sends) is the host the request
+// was sent to. Requests without an origin don't come from web pages, so they are taken to be of the same origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// upgradeWebSocket completes the opening handshake of a websocket connection and takes over the connection from the
+// http server. Nothing is written to `w` if the request isn't a valid opening handshake. Browsers don't keep web pages
+// from opening websocket connections to other origins, so connections from pages of other origins are refused.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		return nil, withCode(CodeMethodNotAllowed, fmt.Errorf("websocket requests must be %s requests", http.MethodGet))
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("unsupported websocket version: %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		return nil, fmt.Errorf("bad websocket key: %q", key)
+	}
+	if !sameOrigin(r) {
+		return nil, withCode(CodeOriginNotAllowed, fmt.Errorf("websocket origin not allowed: %q", r.Header.Get("Origin")))
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can not be upgraded to a websocket")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the http server's deadlines no longer apply.
+	conn.SetDeadline(time.Time{})
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: rw.Reader}, nil
+}
+
+// readFrame reads a frame from the client and unmasks its payload.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c.br, head); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, &wsCloseError{wsCloseProtocolError, "no extensions were negotiated"}
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, &wsCloseError{wsCloseProtocolError, "client frames must be masked"}
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(buf)
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, &wsCloseError{wsCloseProtocolError, "bad control frame"}
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, &wsCloseError{wsCloseTooBig, "message too big"}
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.br, mask); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// readMessage reads the next text or binary message from the client, putting fragmented messages back together and
+// answering pings and the closing handshake along the way. It returns io.EOF once the client has closed the connection.
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	opcode := byte(0)
+	message := []byte{}
+	for {
+		fin, frameOpcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOpcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// echo the close code (if any) to complete the closing handshake.
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			if err := c.writeFrame(wsClose, payload); err != nil && !errors.Is(err, errWebSocketClosed) {
+				return 0, nil, err
+			}
+			return 0, nil, io.EOF
+		case wsContinuation:
+			if opcode == 0 {
+				return 0, nil, &wsCloseError{wsCloseProtocolError, "continuation frame without a message"}
+			}
+		case wsText, wsBinary:
+			if opcode != 0 {
+				return 0, nil, &wsCloseError{wsCloseProtocolError, "message frame before the end of a message"}
+			}
+			opcode = frameOpcode
+		default:
+			return 0, nil, &wsCloseError{wsCloseProtocolError, fmt.Sprintf("unknown opcode: %d", frameOpcode)}
+		}
+
+		if len(message)+len(payload) > maxWebSocketMessage {
+			return 0, nil, &wsCloseError{wsCloseTooBig, "message too big"}
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// writeFrame writes an unfragmented frame to the client. Nothing can be written after a close frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+	if opcode == wsClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeJSON writes the value to the client as a JSON text message.
+func (c *wsConn) writeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, b)
+}
+
+// closeWith starts the closing handshake with the provided close code and reason; the client has wsCloseTimeout to
+// answer before reading from the connection fails.
+func (c *wsConn) closeWith(code int, reason string) error {
+	// the reason has to fit in a control frame along with the code.
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(reason)), uint16(code))
+	err := c.writeFrame(wsClose, append(payload, reason...))
+	c.conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+	return err
+}
+
+// close closes the connection.
+func (c *wsConn) close() error {
+	return c.conn.Close()
+}
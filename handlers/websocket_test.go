@@ -0,0 +1,598 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject/handlers"
+)
+
+// wsKey is the key of the opening handshake example in RFC 6455, and wsAccept the accept key the server answers with.
+const (
+	wsKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+	wsAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+// wsHost is the host opening handshakes are sent to.
+const wsHost = "logs.example:8080"
+
+// wsClient is the client side of a websocket connection, made of frames written by hand.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// wsHandshake sends an opening handshake with the headers to a websocket handler serving log files in `dir` and
+// returns the response along with the connection.
+func wsHandshake(t *testing.T, dir, method string, header http.Header) (*http.Response, *wsClient) {
+	t.Helper()
+	server := httptest.NewServer(handlers.WebSocketHandler(handlers.FxtLogger(), handlers.FxtHost, []string{dir},
+		time.Minute))
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, err := http.NewRequest(method, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header, req.Host = header, wsHost
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	c := &wsClient{t: t, conn: conn, br: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(c.br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, c
+}
+
+// wsHeader returns the headers of a valid opening handshake.
+func wsHeader() http.Header {
+	return http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {wsKey},
+	}
+}
+
+// wsDial opens a websocket connection to a websocket handler serving log files in `dir`.
+func wsDial(t *testing.T, dir string) *wsClient {
+	t.Helper()
+	resp, c := wsHandshake(t, dir, http.MethodGet, wsHeader())
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected handshake status - want: %d, got: %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	return c
+}
+
+// writeRaw writes a frame with the first byte `head`, masked if `masked` is set. The length of the frame is
+// `length` rather than that of the payload if it isn't negative.
+func (c *wsClient) writeRaw(head byte, masked bool, payload []byte, length int) {
+	c.t.Helper()
+	if length < 0 {
+		length = len(payload)
+	}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	frame := []byte{head}
+	switch {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(length))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(length))
+	}
+	if masked {
+		mask := []byte{0x11, 0x22, 0x33, 0x44}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// write writes a masked, unfragmented frame.
+func (c *wsClient) write(opcode byte, payload string) {
+	c.t.Helper()
+	c.writeRaw(0x80|opcode, true, []byte(payload), -1)
+}
+
+// readFrame reads a frame from the server, failing the test if it doesn't arrive within `timeout`. A timeout of
+// zero returns false instead of failing when no frame arrives.
+func (c *wsClient) readFrame(timeout time.Duration) (byte, []byte, bool) {
+	c.t.Helper()
+	wait := timeout
+	if wait == 0 {
+		wait = 500 * time.Millisecond
+	}
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	head := make([]byte, 2)
+	if _, err := c.br.Read(head[:1]); err != nil {
+		var netErr net.Error
+		if timeout == 0 && errors.As(err, &netErr) && netErr.Timeout() {
+			return 0, nil, false
+		}
+		c.t.Fatalf("no frame from the server - error: %s", err)
+	}
+	c.readFull(head[1:])
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		buf := make([]byte, 2)
+		c.readFull(buf)
+		length = uint64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		c.readFull(buf)
+		length = binary.BigEndian.Uint64(buf)
+	}
+	if head[1]&0x80 != 0 {
+		c.t.Fatal("server frames must not be masked")
+	}
+	payload := make([]byte, length)
+	c.readFull(payload)
+	return head[0] & 0x0f, payload, true
+}
+
+// readFull fills the buffer from the connection.
+func (c *wsClient) readFull(buf []byte) {
+	c.t.Helper()
+	if _, err := io.ReadFull(c.br, buf); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readClose reads frames until a close frame and returns its close code.
+func (c *wsClient) readClose() int {
+	c.t.Helper()
+	for {
+		opcode, payload, _ := c.readFrame(2 * time.Second)
+		if opcode != 0x8 {
+			continue
+		}
+		if len(payload) < 2 {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(payload))
+	}
+}
+
+// readMessage reads the next text message, skipping pings, and summarizes it: the line of a line chunk, the event of
+// a marker chunk in angle brackets or the code of an error message after `error:`.
+func (c *wsClient) readMessage(timeout time.Duration) (string, bool) {
+	c.t.Helper()
+	for {
+		opcode, payload, ok := c.readFrame(timeout)
+		if !ok {
+			return "", false
+		}
+		switch opcode {
+		case 0x9, 0xa:
+			continue
+		case 0x1:
+		default:
+			c.t.Fatalf("unexpected frame - opcode: %d, payload: %q", opcode, payload)
+		}
+
+		var msg struct {
+			handlers.TailResponseChunk
+			Error *handlers.APIError `json:"error"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		switch {
+		case msg.Error != nil:
+			return "error:" + string(msg.Error.Code), true
+		case msg.Event != "":
+			return "<" + msg.Event + ">", true
+		}
+		return msg.Line, true
+	}
+}
+
+// readMessages reads `n` text messages (see readMessage).
+func (c *wsClient) readMessages(n int) []string {
+	c.t.Helper()
+	got := []string{}
+	for len(got) < n {
+		msg, _ := c.readMessage(2 * time.Second)
+		got = append(got, msg)
+	}
+	return got
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		header     func(http.Header)
+		wantStatus int
+		wantHeader http.Header
+	}{
+		{
+			name:       "accepted",
+			method:     http.MethodGet,
+			header:     func(http.Header) {},
+			wantStatus: http.StatusSwitchingProtocols,
+			wantHeader: http.Header{"Sec-Websocket-Accept": {wsAccept}, "Upgrade": {"websocket"}},
+		},
+		{
+			name:       "token lists",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Connection", "keep-alive, Upgrade") },
+			wantStatus: http.StatusSwitchingProtocols,
+			wantHeader: http.Header{"Sec-Websocket-Accept": {wsAccept}},
+		},
+		{
+			name:       "same origin",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Origin", "http://"+wsHost) },
+			wantStatus: http.StatusSwitchingProtocols,
+			wantHeader: http.Header{"Sec-Websocket-Accept": {wsAccept}},
+		},
+		{
+			name:       "same origin in another case",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Origin", "https://"+strings.ToUpper(wsHost)) },
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "other origin",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Origin", "https://evil.example") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "other port",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Origin", "http://logs.example:8081") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "opaque origin",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Origin", "null") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "bad version",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Sec-Websocket-Version", "8") },
+			wantStatus: http.StatusBadRequest,
+			wantHeader: http.Header{"Sec-Websocket-Version": {"13"}},
+		},
+		{
+			name:       "key too short",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Sec-Websocket-Key", "c2hvcnQ=") },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "key not base64",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Set("Sec-Websocket-Key", "not a key!") },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not an upgrade",
+			method:     http.MethodGet,
+			header:     func(h http.Header) { h.Del("Upgrade") },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not a get",
+			method:     http.MethodPost,
+			header:     func(http.Header) {},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			header := wsHeader()
+			tc.header(header)
+			resp, _ := wsHandshake(t, t.TempDir(), tc.method, header)
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("unexpected status - want: %d, got: %d", tc.wantStatus, resp.StatusCode)
+			}
+			for name := range tc.wantHeader {
+				if got := resp.Header.Get(name); got != tc.wantHeader.Get(name) {
+					t.Errorf("unexpected %s header - want: %q, got: %q", name, tc.wantHeader.Get(name), got)
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		// send sends frames to the server once the connection is open.
+		send      func(c *wsClient, request string)
+		wantFrame byte
+		// wantPayload is the payload of the frame the server answers with, or the lines the tail request is answered
+		// with (separated by `|`) if wantFrame is a text frame.
+		wantPayload string
+		// wantClose is the close code the server closes the connection with, or 0 if it doesn't.
+		wantClose int
+	}{
+		{
+			name:      "unmasked frame",
+			send:      func(c *wsClient, request string) { c.writeRaw(0x81, false, []byte(request), -1) },
+			wantClose: 1002,
+		},
+		{
+			name:      "oversized frame",
+			send:      func(c *wsClient, request string) { c.writeRaw(0x81, true, nil, 1<<20+1) },
+			wantClose: 1009,
+		},
+		{
+			name: "oversized fragmented message",
+			send: func(c *wsClient, request string) {
+				c.writeRaw(0x01, true, make([]byte, 1<<19+1), -1)
+				c.writeRaw(0x80, true, make([]byte, 1<<19), -1)
+			},
+			wantClose: 1009,
+		},
+		{
+			name:      "continuation without a message",
+			send:      func(c *wsClient, request string) { c.write(0x0, request) },
+			wantClose: 1002,
+		},
+		{
+			name:      "fragmented control frame",
+			send:      func(c *wsClient, request string) { c.writeRaw(0x09, true, []byte("ping"), -1) },
+			wantClose: 1002,
+		},
+		{
+			name: "fragmented message",
+			send: func(c *wsClient, request string) {
+				c.writeRaw(0x01, true, []byte(request[:5]), -1)
+				// control frames can come between the fragments of a message.
+				c.write(0x9, "between")
+				c.writeRaw(0x00, true, []byte(request[5:10]), -1)
+				c.writeRaw(0x80, true, []byte(request[10:]), -1)
+			},
+			wantFrame:   0xa,
+			wantPayload: "between",
+		},
+		{
+			name:        "ping",
+			send:        func(c *wsClient, request string) { c.write(0x9, "are you there") },
+			wantFrame:   0xa,
+			wantPayload: "are you there",
+		},
+		{
+			name:        "close",
+			send:        func(c *wsClient, request string) { c.write(0x8, "\x03\xe9going away") },
+			wantFrame:   0x8,
+			wantPayload: "\x03\xe9",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "frames.log", "one\ntwo\n")
+			c := wsDial(t, filepath.Dir(path))
+			request := `{"path": "` + path + `", "num_lines": 1}`
+			tc.send(c, request)
+
+			if tc.wantClose != 0 {
+				if got := c.readClose(); got != tc.wantClose {
+					t.Errorf("unexpected close code - want: %d, got: %d", tc.wantClose, got)
+				}
+				return
+			}
+			opcode, payload, _ := c.readFrame(2 * time.Second)
+			if opcode != tc.wantFrame || string(payload) != tc.wantPayload {
+				t.Errorf("unexpected frame - want: %d %q, got: %d %q", tc.wantFrame, tc.wantPayload, opcode, payload)
+			}
+		})
+	}
+}
+
+func TestWebSocketFragmentedRequest(t *testing.T) {
+	path := handlers.FxtLogFile(t, "frames.log", "one\ntwo\n")
+	c := wsDial(t, filepath.Dir(path))
+	request := `{"path": "` + path + `", "num_lines": 1}`
+	c.writeRaw(0x01, true, []byte(request[:7]), -1)
+	c.writeRaw(0x80, true, []byte(request[7:]), -1)
+
+	if got := c.readMessages(1); got[0] != "two" {
+		t.Errorf("unexpected line - want: two, got: %q", got[0])
+	}
+}
+
+func TestWebSocketRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{name: "bad json", request: `{"path": `, want: "error:invalid_request"},
+		{name: "not followable", request: `{"path": "%s", "include_rotated": true}`, want: "error:invalid_request"},
+		{name: "bad filter", request: `{"path": "%s", "match_regex": "a(b"}`, want: "error:invalid_filter"},
+		{name: "path not allowed", request: `{"path": "/etc/passwd"}`, want: "error:path_not_allowed"},
+		{name: "not found", request: `{"path": "%s.missing"}`, want: "error:file_not_found"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "refused.log", "one\n")
+			c := wsDial(t, filepath.Dir(path))
+			c.write(0x1, strings.ReplaceAll(tc.request, "%s", path))
+
+			if got := c.readMessages(1); got[0] != tc.want {
+				t.Errorf("unexpected message - want: %s, got: %s", tc.want, got[0])
+			}
+			if got := c.readClose(); got != 1008 {
+				t.Errorf("unexpected close code - want: 1008, got: %d", got)
+			}
+		})
+	}
+}
+
+func TestWebSocketOriginRefused(t *testing.T) {
+	header := wsHeader()
+	header.Set("Origin", "https://evil.example")
+	resp, _ := wsHandshake(t, t.TempDir(), http.MethodGet, header)
+	var errResp handlers.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatal(err)
+	}
+	if errResp.Error == nil || errResp.Error.Code != handlers.CodeOriginNotAllowed {
+		t.Errorf("unexpected error - want: %s, got: %#v", handlers.CodeOriginNotAllowed, errResp.Error)
+	}
+}
+
+func TestWebSocketRestartRefused(t *testing.T) {
+	path := handlers.FxtLogFile(t, "removed.log", "one\n")
+	c := wsDial(t, filepath.Dir(path))
+	c.write(0x1, `{"path": "`+path+`", "num_lines": 1}`)
+	c.readMessages(1)
+
+	// following can't start over once the log file is gone.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	c.write(0x1, `{"action": "num_lines", "num_lines": 2}`)
+	if got := c.readMessages(1); got[0] != "error:file_not_found" {
+		t.Errorf("unexpected message - want: error:file_not_found, got: %s", got[0])
+	}
+	if got := c.readClose(); got != 1008 {
+		t.Errorf("unexpected close code - want: 1008, got: %d", got)
+	}
+}
+
+func TestWebSocketControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		// want are the messages each control message is answered with.
+		want [][]string
+	}{
+		{
+			name:     "filter",
+			messages: []string{`{"action": "filter", "match_substrings": ["one", "three"]}`},
+			want:     [][]string{{"<restarted>", "one", "three"}},
+		},
+		{
+			name:     "filter cleared",
+			messages: []string{`{"action": "filter", "match_regex": "^o"}`, `{"action": "filter"}`},
+			want:     [][]string{{"<restarted>", "one"}, {"<restarted>", "two", "three"}},
+		},
+		{
+			name:     "num_lines",
+			messages: []string{`{"action": "num_lines", "num_lines": 3}`},
+			want:     [][]string{{"<restarted>", "one", "two", "three"}},
+		},
+		{
+			name:     "negative num_lines",
+			messages: []string{`{"action": "num_lines", "num_lines": -1}`, `{"action": "num_lines", "num_lines": 1}`},
+			want:     [][]string{{"error:invalid_request"}, {"<restarted>", "three"}},
+		},
+		{
+			name:     "bad filter",
+			messages: []string{`{"action": "filter", "match_regex": "a(b"}`, `{"action": "num_lines", "num_lines": 1}`},
+			want:     [][]string{{"error:invalid_filter"}, {"<restarted>", "three"}},
+		},
+		{
+			name:     "unknown action",
+			messages: []string{`{"action": "rewind"}`, `{"action": "pause"}`},
+			want:     [][]string{{"error:invalid_request"}, {"<paused>"}},
+		},
+		{
+			name:     "bad json",
+			messages: []string{`{"action": `, `{"action": "pause"}`},
+			want:     [][]string{{"error:invalid_request"}, {"<paused>"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := handlers.FxtLogFile(t, "control.log", "one\ntwo\nthree\n")
+			c := wsDial(t, filepath.Dir(path))
+			c.write(0x1, `{"path": "`+path+`", "num_lines": 2}`)
+			want := []string{"two", "three"}
+			if got := c.readMessages(2); strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Fatalf("unexpected initial messages - want: %#v, got: %#v", want, got)
+			}
+
+			for i, msg := range tc.messages {
+				c.write(0x1, msg)
+				if got := c.readMessages(len(tc.want[i])); strings.Join(got, "|") != strings.Join(tc.want[i], "|") {
+					t.Errorf("unexpected answer to %s - want: %#v, got: %#v", msg, tc.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocketPauseResume(t *testing.T) {
+	path := handlers.FxtLogFile(t, "paused.log", "one\n")
+	c := wsDial(t, filepath.Dir(path))
+	c.write(0x1, `{"path": "`+path+`", "num_lines": 1}`)
+	c.readMessages(1)
+
+	c.write(0x1, `{"action": "pause"}`)
+	if got := c.readMessages(1); got[0] != "<paused>" {
+		t.Fatalf("unexpected message - want: <paused>, got: %s", got[0])
+	}
+	handlers.FxtAppend(t, path, "two\n")
+	// well past the interval the log file is polled at.
+	if msg, ok := c.readMessage(0); ok {
+		t.Errorf("unexpected message while paused: %s", msg)
+	}
+
+	c.write(0x1, `{"action": "resume"}`)
+	want := []string{"<resumed>", "two"}
+	if got := c.readMessages(2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected messages - want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestWebSocketClientClose(t *testing.T) {
+	path := handlers.FxtLogFile(t, "closed.log", "one\n")
+	c := wsDial(t, filepath.Dir(path))
+	c.write(0x1, `{"path": "`+path+`", "num_lines": 1}`)
+	c.readMessages(1)
+
+	// the close code is echoed to complete the closing handshake and the server closes the connection.
+	c.write(0x8, "\x03\xe8")
+	if got := c.readClose(); got != 1000 {
+		t.Errorf("unexpected close code - want: 1000, got: %d", got)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.br.ReadByte(); err == nil || os.IsTimeout(err) {
+		t.Errorf("connection left open after the closing handshake - error: %v", err)
+	}
+}
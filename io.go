@@ -1,9 +1,12 @@
 package cproject
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"os"
+	"strings"
 )
 
 const (
@@ -11,6 +14,8 @@ const (
 	stdBufSize int64 = 4096
 	// newline is a newline
 	newline byte = '\n'
+	// endOfFile is an offset meaning the end of the file, wherever that is when it's read.
+	endOfFile int64 = -1
 )
 
 // LineBuffer is a wrapper around a bytes.Buffer. The underlying buffer stores bytes in reverse order
@@ -104,27 +109,46 @@ func includeLine(lineBuf *LineBuffer, filters []Filter) (bool, string) {
 	}
 
 	line := lineBuf.String()
+	return passesFilters(line, filters), line
+}
+
+// passesFilters returns true if there are no filters or the line is included by at least one of the filters.
+func passesFilters(line string, filters []Filter) bool {
 	if len(filters) == 0 {
-		return true, line
+		return true
 	}
 
 	for _, filter := range filters {
 		if filter.Include(line) {
-			return true, line
+			return true
 		}
 	}
 
-	return false, line
+	return false
 }
 
-// yieldLines reads up to `numLines` lines from the provided file. The file is read in reverse building
-// lines as they are identified (line = SOF,\n; \n,\n; \n,EOF). If `numLines` is 0 or less, all lines are returned.
-// If `filters` are provided, only lines that pass the filters are returned. When a line is identified and passes
-// filters, it is yielded to the lines channel. If an error is encountered, processes stops and an error is returned
-// on the `errChan`. When `yieldLines` is successful, both the lines channel will be closed with no further values.
-//
-// TODO: Dissect this function into smaller, managable functions.
-func yieldLines(file *os.File, numLines int, filters []Filter, lines chan<- string, errChan chan<- error) {
+// lineSpan describes where a line lives in a file; `start` is the offset of the first byte of the line and `end` is
+// the offset just past the last byte of the line.
+type lineSpan struct {
+	start int64
+	end   int64
+}
+
+// read reads the bytes described by the span from the file.
+func (s lineSpan) read(file io.ReaderAt) (string, error) {
+	buf := make([]byte, s.end-s.start)
+	if _, err := file.ReadAt(buf, s.start); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// scanLines reads the provided file in reverse building lines as they are identified (line = SOF,\n; \n,\n; \n,EOF).
+// Reading starts at the byte offset `end`, or the end of the file if `end` is negative (endOfFile). Every time a line
+// is identified `fn` is called with the line buffer and the byte offset the line starts at. The line buffer is reset
+// after `fn` returns so it must not be retained. Scanning stops when `fn` returns false, the beginning of the file is
+// reached or an error is encountered.
+func scanLines(file *os.File, end int64, fn func(lineBuf *LineBuffer, offset int64) bool) error {
 	// ensure we rewind the pointer when we're done
 	defer func() { file.Seek(0, io.SeekStart) }()
 
@@ -133,110 +157,296 @@ func yieldLines(file *os.File, numLines int, filters []Filter, lines chan<- stri
 		bufSz int64 = stdBufSize
 		// buf is the read buffer.
 		buf []byte = make([]byte, bufSz)
-		// pos is the current seek position of the file.
-		pos int64 = 0
-
-		// nlCount collects the count of yielded lines.
-		nlCount = 0
 		// firstRead is a flag that, when true, indicates we haven't read any lines yet.
 		firstRead = true
+		// pos is the current seek position of the file.
+		pos int64
+		err error
 	)
 
-	// Determine the best seek position to start reading from.
-	pos, err := startPos(bufSz, file)
-	if err != nil {
-		close(lines)
-		errChan <- err
-		close(errChan)
-		return
-	}
+	if end < 0 {
+		// Determine the best seek position to start reading from.
+		pos, err = startPos(bufSz, file)
+		if err != nil {
+			return err
+		}
 
-	// If we're not reading from the beginning of the file (because the file is bigger than the buffer), then seek to
-	// that position.
-	if pos > 0 {
-		pos, err = file.Seek(-pos, io.SeekEnd)
+		// If we're not reading from the beginning of the file (because the file is bigger than the buffer), then seek
+		// to that position.
+		if pos > 0 {
+			pos, err = file.Seek(-pos, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		// Read the buffer that ends at `end`, truncating the buffer if it's too close to the beginning of the file.
+		pos = end - bufSz
+		if pos < 0 {
+			pos = 0
+		}
+		buf = buf[:end-pos]
+		pos, err = file.Seek(pos, io.SeekStart)
 		if err != nil {
-			close(lines)
-			errChan <- err
-			close(errChan)
-			return
+			return err
 		}
 	}
 
 	// lineBuf is buffer that collects bytes from a single line in the file.
 	lineBuf := NewLineBuffer()
 
-	// Loop, reading chunks of the file and yielding lines as they are identified.
+	// Loop, reading chunks of the file and identifying lines as we go.
 	for {
 		// after this, the seek pos will be pos + sz
 		sz, err := file.Read(buf)
-		if err != nil {
-			if err != io.EOF {
-				close(lines)
-				errChan <- err
-				close(errChan)
-			}
+		if err != nil && err != io.EOF {
+			return err
 		}
 
-		// Determine the best start and end index for reading the bytes in the buffer.
-		start, end := sz-1, 0
+		// Determine the best start index for reading the bytes in the buffer.
+		start := sz - 1
 		if firstRead {
 			// adjust the start to account for trailing newlines at the end of the file.
-			start -= int(countTrailingNewlines(buf))
+			start -= int(countTrailingNewlines(buf[:sz]))
 			firstRead = false
 		}
 
-		// Everytime we come across a newline, check to see if we should yield it.
-		for i := start; i >= end; i-- {
+		// Everytime we come across a newline, hand the line to the caller.
+		for i := start; i >= 0; i-- {
 			if buf[i] == newline {
-				if include, line := includeLine(lineBuf, filters); include {
-					lines <- line
-					nlCount++
-					// If we've yielded the requested number of lines, we're done.
-					if numLines > 0 && nlCount == numLines {
-						close(lines)
-						close(errChan)
-						return
-					}
+				if !fn(lineBuf, pos+int64(i)+1) {
+					return nil
 				}
-				// Reset the line buffer regardless if we've yielded the line.
+				// Reset the line buffer regardless of what the caller did with it.
 				lineBuf.Reset()
-			} else {
-				// Append the read byte to the line buffer.
-				err := lineBuf.WriteByte(buf[i])
-				if err != nil {
-					close(lines)
-					errChan <- err
-					close(errChan)
-				}
+			} else if err := lineBuf.WriteByte(buf[i]); err != nil {
+				return err
 			}
 		}
 
-		// If we've read less than a full buffer size then we've truncated the buffer
-		// on the previous pass and reached the beginning of the file and we're done.
-		if sz < int(stdBufSize) {
-			if include, line := includeLine(lineBuf, filters); include {
-				lines <- line
+		// If this buffer was read from the beginning of the file, the line buffer holds the first line and we're done.
+		if pos == 0 {
+			fn(lineBuf, 0)
+			return nil
+		}
+
+		// Move the pointer back another buffer; if there aren't enough bytes left to fill the buffer, seek to the
+		// beginning of the file and truncate the buffer.
+		next := pos - bufSz
+		if next < 0 {
+			next = 0
+		}
+		buf = buf[:pos-next]
+		pos, err = file.Seek(next, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// scanForward reads the part of the provided file described by `within` from beginning to end, calling `fn` with each
+// line (empty lines included) and the byte offset it starts at. Scanning stops when `fn` returns false, the end of
+// `within` is reached or an error is encountered.
+func scanForward(file *os.File, within lineSpan, fn func(line string, offset int64) bool) error {
+	section, err := sectionReader(file, within)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(section, int(stdBufSize))
+	offset := within.start
+	for {
+		text, err := reader.ReadString(newline)
+		if len(text) > 0 {
+			if !fn(strings.TrimSuffix(text, string(newline)), offset) {
+				return nil
 			}
-			close(lines)
-			close(errChan)
-			return
+			offset += int64(len(text))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
+	}
+}
 
-		// determine how far to move the pointer by seeing if there's enough bytes to fill the buffer
-		// else seek to pos 0 and truncate the buffer
-		offset := bufSz * 2 // rewind to beginning of current buffer plus another buffer
-		err = nil
-		if pos-offset > 0 {
-			pos, err = file.Seek(-offset, io.SeekCurrent)
-		} else {
-			buf = make([]byte, pos)
-			pos, err = file.Seek(0, io.SeekStart)
+// fileSize returns the size of the provided file.
+func fileSize(file *os.File) (int64, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// sectionReader returns a reader of the part of the provided file described by `within` (a negative end is the end
+// of the file).
+func sectionReader(file *os.File, within lineSpan) (io.Reader, error) {
+	end := within.end
+	if end < 0 {
+		size, err := fileSize(file)
+		if err != nil {
+			return nil, err
+		}
+		end = size
+	}
+	if end < within.start {
+		end = within.start
+	}
+	return io.NewSectionReader(file, within.start, end-within.start), nil
+}
+
+// wholeFile is the span of an entire file.
+var wholeFile = lineSpan{start: 0, end: endOfFile}
+
+// collectSpans scans the provided file in reverse and returns the spans of up to `numLines` lines within `within`
+// that pass the filters. The spans are ordered newest to oldest. Scanning stops early with the context's error if the
+// context is done.
+func collectSpans(ctx context.Context, file *os.File, within lineSpan, numLines int, filters []Filter) ([]lineSpan,
+	error) {
+	spans := []lineSpan{}
+	err := scanLines(file, within.end, func(lineBuf *LineBuffer, offset int64) bool {
+		if ctx.Err() != nil || offset < within.start {
+			return false
 		}
+		if include, _ := includeLine(lineBuf, filters); !include {
+			return true
+		}
+		spans = append(spans, lineSpan{start: offset, end: offset + int64(lineBuf.Len())})
+		return numLines <= 0 || len(spans) < numLines
+	})
+	if err != nil {
+		return spans, err
+	}
+	return spans, ctx.Err()
+}
+
+// tailLines reads up to `numLines` lines from the end of the part of the provided file described by `within` (a
+// negative end is the end of the file) and passes them to `fn` in the requested order. If `numLines` is 0 or less,
+// all lines are read. If `filters` are provided, only lines that pass the filters are passed to `fn`. Lines are passed
+// newest first as they are identified while reading the file in reverse. Lines in forward order require two passes:
+// the file is scanned in reverse recording the span of each line that passes the filters, then each span is read
+// again and passed oldest to newest. Reading stops when `fn` returns false. If the context is done before reading
+// finishes, the context's error is returned.
+func tailLines(ctx context.Context, file *os.File, within lineSpan, numLines int, filters []Filter, order Order,
+	fn func(Line) bool) error {
+	if order == OrderReverse {
+		// nlCount collects the count of lines passed along.
+		nlCount := 0
+		err := scanLines(file, within.end, func(lineBuf *LineBuffer, offset int64) bool {
+			if ctx.Err() != nil || offset < within.start {
+				return false
+			}
+			include, line := includeLine(lineBuf, filters)
+			if !include {
+				return true
+			}
+			if !fn(Line{Text: line, Offset: offset}) {
+				return false
+			}
+			nlCount++
+			// If we've passed along the requested number of lines, we're done.
+			return numLines <= 0 || nlCount < numLines
+		})
 		if err != nil {
-			close(lines)
-			errChan <- err
-			close(errChan)
+			return err
+		}
+		return ctx.Err()
+	}
+
+	spans, err := collectSpans(ctx, file, within, numLines, filters)
+	if err != nil {
+		return err
+	}
+	for i := len(spans) - 1; i >= 0; i-- {
+		text, err := spans[i].read(file)
+		if err != nil {
+			return err
+		}
+		if !fn(Line{Text: text, Offset: spans[i].start}) {
+			break
 		}
 	}
+	return ctx.Err()
+}
+
+// yieldLines reads up to `numLines` lines from the provided file. The file is read in reverse building
+// lines as they are identified (line = SOF,\n; \n,\n; \n,EOF). If `numLines` is 0 or less, all lines are returned.
+// If `filters` are provided, only lines that pass the filters are returned. When a line is identified and passes
+// filters, it is yielded to the lines channel. If an error is encountered, processes stops and an error is returned
+// on the `errChan`. When `yieldLines` is successful, both the lines channel will be closed with no further values.
+func yieldLines(file *os.File, numLines int, filters []Filter, lines chan<- string, errChan chan<- error) {
+	defer close(errChan)
+	defer close(lines)
+
+	err := tailLines(context.Background(), file, wholeFile, numLines, filters, OrderReverse, func(line Line) bool {
+		lines <- line.Text
+		return true
+	})
+	if err != nil {
+		errChan <- err
+	}
+}
+
+// yieldLinesForward reads up to `numLines` lines from the provided file and yields them in the order they are found
+// in the file. Channel semantics are the same as `yieldLines`.
+func yieldLinesForward(file *os.File, numLines int, filters []Filter, lines chan<- string, errChan chan<- error) {
+	defer close(errChan)
+	defer close(lines)
+
+	err := tailLines(context.Background(), file, wholeFile, numLines, filters, OrderForward, func(line Line) bool {
+		lines <- line.Text
+		return true
+	})
+	if err != nil {
+		errChan <- err
+	}
+}
+
+// tailFunc reads lines and passes them to `fn` until there are no more lines or `fn` returns false.
+type tailFunc func(ctx context.Context, fn func(Line) bool) error
+
+// streamText runs `tail` in a goroutine yielding the text of each line to a string channel. If `tail` fails, the
+// error is sent on the error channel. Both channels are closed when `tail` returns.
+func streamText(tail tailFunc) (chan string, chan error) {
+	lines := make(chan string, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(lines)
+
+		err := tail(context.Background(), func(line Line) bool {
+			lines <- line.Text
+			return true
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return lines, errChan
+}
+
+// streamLines runs `tail` in a goroutine yielding each line to a line channel until the context is done. If `tail`
+// fails, or the context is done first, the error is sent on the error channel. Both channels are closed when `tail`
+// returns.
+func streamLines(ctx context.Context, tail tailFunc) (chan Line, chan error) {
+	lines := make(chan Line, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(lines)
+
+		err := tail(ctx, func(line Line) bool {
+			return sendLine(ctx, lines, line)
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return lines, errChan
 }
@@ -1,6 +1,8 @@
 package cproject
 
 import (
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -314,3 +316,124 @@ func TestYieldLinesFiltered(t *testing.T) {
 		})
 	}
 }
+
+func TestYieldLinesForward(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		lines   int
+		filters []Filter
+		want    []string
+	}{
+		{
+			desc:  "lastOne",
+			lines: 1,
+			want: []string{
+				"and off-by-1 errors.",
+			},
+		}, {
+			desc:  "lastThree",
+			lines: 3,
+			want: []string{
+				"cache invalidation,",
+				"naming things,",
+				"and off-by-1 errors.",
+			},
+		}, {
+			desc:  "moreThanActual",
+			lines: 10,
+			want: []string{
+				"There are 2 hard problems in computer science:",
+				"cache invalidation,",
+				"naming things,",
+				"and off-by-1 errors.",
+			},
+		}, {
+			desc:  "filtered",
+			lines: 10,
+			filters: []Filter{
+				NewMatchAnySubstring(WithSubstrings([]string{"cache", "thing"})),
+			},
+			want: []string{
+				"cache invalidation,",
+				"naming things,",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			content := FxtContent()
+			file, err := FxtFile(t, content)
+			if err != nil {
+				t.Error(err)
+			}
+
+			lines := make(chan string, 1)
+			errChan := make(chan error, 1)
+
+			go yieldLinesForward(file, tC.lines, tC.filters, lines, errChan)
+
+			var got []string
+
+			for line := range lines {
+				got = append(got, line)
+			}
+			err = <-errChan
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !StringSlicesEqual(tC.want, got) {
+				t.Errorf("unexpected results - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestYieldLinesAcrossBuffers(t *testing.T) {
+	file, err := os.Open("./testdata/number_lines.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	testCases := []struct {
+		desc  string
+		yield func(*os.File, int, []Filter, chan<- string, chan<- error)
+		want  []string
+	}{
+		{
+			desc:  "reverse",
+			yield: yieldLines,
+			want:  []string{"1000", "0999", "0998"},
+		}, {
+			desc:  "forward",
+			yield: yieldLinesForward,
+			want:  []string{"0001", "0002", "0003"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			lines := make(chan string, 1)
+			errChan := make(chan error, 1)
+
+			go tC.yield(file, 0, nil, lines, errChan)
+
+			var got []string
+			for line := range lines {
+				got = append(got, line)
+			}
+			if err := <-errChan; err != nil {
+				t.Error(err)
+			}
+
+			if len(got) != 1000 {
+				t.Fatalf("unexpected number of lines - want: %d, got: %d", 1000, len(got))
+			}
+			for i, prefix := range tC.want {
+				if !strings.HasPrefix(got[i], prefix+" ") || len(got[i]) != 79 {
+					t.Errorf("unexpected line %d - want prefix: %s, got: %s", i, prefix, got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package cproject
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	// LevelTrace is the most verbose level.
+	LevelTrace Level = iota
+	// LevelDebug is for debugging messages.
+	LevelDebug
+	// LevelInfo is for informational messages.
+	LevelInfo
+	// LevelNotice is for normal but significant conditions.
+	LevelNotice
+	// LevelWarn is for warning conditions.
+	LevelWarn
+	// LevelError is for error conditions.
+	LevelError
+	// LevelCritical is for critical conditions (also fatal and panic).
+	LevelCritical
+	// LevelAlert is for conditions that must be acted on immediately.
+	LevelAlert
+	// LevelEmergency is for when the system is unusable.
+	LevelEmergency
+)
+
+var (
+	// levelNames are the names of the levels by level.
+	levelNames = []string{"trace", "debug", "info", "notice", "warn", "error", "critical", "alert", "emergency"}
+
+	// levelAliases are the other names of levels found in log lines.
+	levelAliases = map[string]Level{
+		"trc":           LevelTrace,
+		"dbg":           LevelDebug,
+		"inf":           LevelInfo,
+		"information":   LevelInfo,
+		"informational": LevelInfo,
+		"warning":       LevelWarn,
+		"wrn":           LevelWarn,
+		"err":           LevelError,
+		"crit":          LevelCritical,
+		"fatal":         LevelCritical,
+		"panic":         LevelCritical,
+		"emerg":         LevelEmergency,
+	}
+
+	// levelLetters are the levels by the single letters used for them (e.g. `[E]` or glog's `E0220 07:10:42`).
+	levelLetters = map[byte]Level{
+		'T': LevelTrace,
+		'D': LevelDebug,
+		'I': LevelInfo,
+		'N': LevelNotice,
+		'W': LevelWarn,
+		'E': LevelError,
+		'C': LevelCritical,
+		'F': LevelCritical,
+	}
+
+	// syslogLevels are the levels of the syslog severities by code.
+	syslogLevels = []Level{
+		LevelEmergency, LevelAlert, LevelCritical, LevelError, LevelWarn, LevelNotice, LevelInfo, LevelDebug,
+	}
+
+	// priLevel matches the syslog PRI at the start of a line.
+	priLevel = regexp.MustCompile(`^<(\d{1,3})>`)
+	// fieldLevel matches a level field in structured lines such as `level=error` or `"level":"error"`.
+	fieldLevel = regexp.MustCompile(`(?i)\b(?:level|lvl|severity|loglevel)"?\s*[=:]\s*"?([a-z]+)`)
+	// letterLevel matches a single letter level in brackets or at the start of a line in the glog format.
+	letterLevel = regexp.MustCompile(`\[([TDINWECF])\]|^([IWEF])\d{4} `)
+	// wordLevel matches an upper case level name.
+	wordLevel = regexp.MustCompile(
+		`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|CRIT|CRITICAL|FATAL|PANIC|ALERT|EMERG|EMERGENCY)\b`)
+)
+
+// ParseLevel returns the Level named by the provided string, ignoring case. Common aliases such as `warning`, `err`
+// and `fatal` are recognized.
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToLower(s)
+	for level, levelName := range levelNames {
+		if name == levelName {
+			return Level(level), nil
+		}
+	}
+	if level, ok := levelAliases[name]; ok {
+		return level, nil
+	}
+	return LevelTrace, fmt.Errorf("unknown level: %q", s)
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// DetectLevel finds the level of a log line. The conventions recognized, in order of precedence, are a syslog PRI
+// (`<11>`), a level field (`level=error`, `"level":"error"`), a single letter level (`[E]` or glog's
+// `E0220 07:10:42`) and an upper case level name (`ERROR`). Only the start of the line is searched, except for a level
+// field: the fields of JSON and logfmt lines can be in any order, so the level can come after a long message.
+func DetectLevel(line string) (Level, bool) {
+	start := line
+	if len(start) > timestampSearchLen {
+		start = start[:timestampSearchLen]
+	}
+
+	if m := priLevel.FindStringSubmatch(start); m != nil {
+		if priority, err := strconv.Atoi(m[1]); err == nil && priority <= 191 {
+			return syslogLevels[priority%8], true
+		}
+	}
+	if m := fieldLevel.FindStringSubmatch(line); m != nil {
+		if level, err := ParseLevel(m[1]); err == nil {
+			return level, true
+		}
+	}
+	if m := letterLevel.FindStringSubmatch(start); m != nil {
+		return levelLetters[(m[1] + m[2])[0]], true
+	}
+	if m := wordLevel.FindStringSubmatch(start); m != nil {
+		if level, err := ParseLevel(m[1]); err == nil {
+			return level, true
+		}
+	}
+	return LevelTrace, false
+}
+
+// MinLevel is a filter that includes lines at or above a level (see DetectLevel). Lines without a level aren't
+// included.
+type MinLevel struct {
+	Level Level
+}
+
+// Include determines if a line of text should be included in the result set.
+func (f MinLevel) Include(s string) bool {
+	level, ok := DetectLevel(s)
+	return ok && level >= f.Level
+}
@@ -0,0 +1,144 @@
+package cproject_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		s       string
+		want    cproject.Level
+		wantErr bool
+	}{
+		{desc: "name", s: "error", want: cproject.LevelError},
+		{desc: "upperCase", s: "WARN", want: cproject.LevelWarn},
+		{desc: "alias", s: "warning", want: cproject.LevelWarn},
+		{desc: "fatal", s: "Fatal", want: cproject.LevelCritical},
+		{desc: "unknown", s: "loud", wantErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, err := cproject.ParseLevel(tC.s)
+			if tC.wantErr != (err != nil) {
+				t.Fatalf("unexpected error - want error: %t, got: %v", tC.wantErr, err)
+			}
+			if !tC.wantErr && tC.want != got {
+				t.Errorf("unexpected level - want: %s, got: %s", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestDetectLevel(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		line   string
+		want   cproject.Level
+		wantOk bool
+	}{
+		{
+			desc:   "upperCaseWord",
+			line:   "2024-02-20T07:10:42Z ERROR GET /healthcheck 503",
+			want:   cproject.LevelError,
+			wantOk: true,
+		}, {
+			desc:   "bracketedWord",
+			line:   "2024-02-20 07:10:42 [WARNING] disk almost full",
+			want:   cproject.LevelWarn,
+			wantOk: true,
+		}, {
+			desc:   "bracketedLetter",
+			line:   "07:10:42.003 [E] upstream timed out",
+			want:   cproject.LevelError,
+			wantOk: true,
+		}, {
+			desc:   "glog",
+			line:   "W0220 07:10:42.003412    4242 server.go:42] slow request",
+			want:   cproject.LevelWarn,
+			wantOk: true,
+		}, {
+			desc:   "logfmt",
+			line:   `ts=2024-02-20T07:10:42Z level=info msg="recovered from ERROR state"`,
+			want:   cproject.LevelInfo,
+			wantOk: true,
+		}, {
+			desc:   "json",
+			line:   `{"ts":"2024-02-20T07:10:42Z","level":"error","msg":"boom"}`,
+			want:   cproject.LevelError,
+			wantOk: true,
+		}, {
+			desc:   "jsonSeverity",
+			line:   `{"severity": "CRITICAL", "message": "boom"}`,
+			want:   cproject.LevelCritical,
+			wantOk: true,
+		}, {
+			// the level field is found after a message longer than the start of the line that's searched, and wins
+			// over the level name in the message.
+			desc:   "jsonLongMessage",
+			line:   `{"ts":"2024-02-20T07:10:42Z","msg":"ERROR ` + strings.Repeat("monkey ", 60) + `","level":"warn"}`,
+			want:   cproject.LevelWarn,
+			wantOk: true,
+		}, {
+			desc:   "logfmtLongMessage",
+			line:   `ts=2024-02-20T07:10:42Z msg="` + strings.Repeat("monkey ", 60) + `" level=debug`,
+			want:   cproject.LevelDebug,
+			wantOk: true,
+		}, {
+			desc:   "wordAfterLongMessage",
+			line:   "2024-02-20T07:10:42Z " + strings.Repeat("monkey ", 60) + "ERROR",
+			wantOk: false,
+		}, {
+			desc:   "syslogPRI",
+			line:   "<11>Feb 20 07:10:42 zoo monkey[42]: out of bananas",
+			want:   cproject.LevelError,
+			wantOk: true,
+		}, {
+			desc:   "lowerCaseProse",
+			line:   "2024-02-20T07:10:42Z retrying after an error",
+			wantOk: false,
+		}, {
+			desc:   "none",
+			line:   "fed the monkey 2 bananas",
+			wantOk: false,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := cproject.DetectLevel(tC.line)
+			if tC.wantOk != ok {
+				t.Fatalf("unexpected detection - want: %t, got: %t (%s)", tC.wantOk, ok, got)
+			}
+			if ok && tC.want != got {
+				t.Errorf("unexpected level - want: %s, got: %s", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+	fxtLines := []string{
+		"2024-02-20T07:10:40Z DEBUG fed the monkey",
+		"2024-02-20T07:10:41Z WARN monkey still hungry",
+		"2024-02-20T07:10:42Z ERROR out of bananas",
+		"    at feed (zoo.go:42)",
+		"2024-02-20T07:10:43Z ERROR out of crabs",
+	}
+	filter := cproject.All{
+		cproject.MinLevel{Level: cproject.LevelWarn},
+		cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"bananas", "hungry"})),
+	}
+
+	var got []string
+	for _, line := range fxtLines {
+		if filter.Include(line) {
+			got = append(got, line)
+		}
+	}
+	if want := fxtLines[1:3]; !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+}
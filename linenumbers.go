@@ -0,0 +1,94 @@
+package cproject
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// maxLineNumberScan is the most bytes counted through to number a line. Lines further than this from the start of
+// the file, or from the last line numbered, aren't numbered.
+const maxLineNumberScan = 64 << 20
+
+// lineCounter numbers lines by their offsets. The newlines between the last line numbered and the next one are
+// counted, so lines read near each other (in either direction) are cheap to number.
+type lineCounter struct {
+	file *os.File
+	// offset is the offset of the last line numbered.
+	offset int64
+	// number is the line number of the last line numbered, or 0 if no line has been numbered.
+	number int64
+	buf    []byte
+	// err is the error encountered numbering lines, if any.
+	err error
+}
+
+// newLineCounter creates a new lineCounter of the lines of the provided file.
+func newLineCounter(file *os.File) *lineCounter {
+	return &lineCounter{
+		file: file,
+		buf:  make([]byte, stdBufSize),
+	}
+}
+
+// count returns the number of newlines from `from` up to (not including) `to`.
+func (c *lineCounter) count(from, to int64) (int64, error) {
+	count := int64(0)
+	for from < to {
+		buf := c.buf
+		if to-from < int64(len(buf)) {
+			buf = buf[:to-from]
+		}
+		n, err := c.file.ReadAt(buf, from)
+		count += int64(bytes.Count(buf[:n], []byte{newline}))
+		from += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// lineNumber returns the 1-based line number of the line starting at `offset`, or 0 if it's too far from the last
+// line numbered to count.
+func (c *lineCounter) lineNumber(offset int64) (int64, error) {
+	from, number := c.offset, c.number
+	if number == 0 {
+		from, number = 0, 1
+	}
+	if offset-from > maxLineNumberScan || from-offset > maxLineNumberScan {
+		return 0, nil
+	}
+
+	if offset >= from {
+		n, err := c.count(from, offset)
+		if err != nil {
+			return 0, err
+		}
+		number += n
+	} else {
+		n, err := c.count(offset, from)
+		if err != nil {
+			return 0, err
+		}
+		number -= n
+	}
+	c.offset, c.number = offset, number
+	return number, nil
+}
+
+// numbered returns a function that numbers the lines of text passed to it before passing them to `fn`. Numbering
+// stops, and so does the function, when an error is encountered; the error is left in `err`.
+func (c *lineCounter) numbered(fn func(Line) bool) func(Line) bool {
+	return func(line Line) bool {
+		if line.Kind == LineText || line.Kind == LineContext {
+			if line.Number, c.err = c.lineNumber(line.Offset); c.err != nil {
+				return false
+			}
+		}
+		return fn(line)
+	}
+}
@@ -0,0 +1,159 @@
+package cproject_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// fxtContextPosition describes a line of the context fixture with its line number and offset.
+func fxtContextPosition(i int) string {
+	offset := 0
+	for _, line := range fxtContextLines[:i] {
+		offset += len(line) + 1
+	}
+	return fmt.Sprintf("%d@%d %s", i+1, offset, fxtContextLines[i])
+}
+
+func TestLogFileWithLineNumbers(t *testing.T) {
+	errorLines := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+	timestampStart, err := cproject.NewMatchRegexp(`^\d{4}-\d{2}-\d{2}T`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc    string
+		lines   int
+		order   cproject.Order
+		filters []cproject.Filter
+		want    []string
+	}{
+		{
+			desc:  "reverse",
+			lines: 2,
+			want:  []string{fxtContextPosition(7), fxtContextPosition(6)},
+		}, {
+			desc:  "forward",
+			lines: 2,
+			order: cproject.OrderForward,
+			want:  []string{fxtContextPosition(6), fxtContextPosition(7)},
+		}, {
+			desc:    "filtered",
+			lines:   10,
+			order:   cproject.OrderForward,
+			filters: []cproject.Filter{errorLines},
+			want:    []string{fxtContextPosition(2), fxtContextPosition(6)},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				for _, start := range []cproject.Filter{nil, timestampStart} {
+					logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order),
+						cproject.WithRecordStart(start), cproject.WithLineNumbers(true))
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer logFile.Close()
+
+					lines, errChan := logFile.YieldLinesContext(context.Background(), tC.lines, tC.filters...)
+
+					var got []string
+					for line := range lines {
+						got = append(got, fmt.Sprintf("%d@%d %s", line.Number, line.Offset, line.Text))
+					}
+					if err := <-errChan; err != nil {
+						t.Error(err)
+					}
+
+					if !cproject.StringSlicesEqual(tC.want, got) {
+						t.Errorf("unexpected results for %s (records: %t) - want: %#v, got: %#v", filepath.Base(path),
+							start != nil, tC.want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLogFileWithoutLineNumbers(t *testing.T) {
+	plain, compressed := FxtContextFile(t)
+
+	for _, path := range []string{plain, compressed} {
+		logFile, err := cproject.OpenLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logFile.Close()
+
+		lines, errChan := logFile.YieldLinesContext(context.Background(), 0)
+		for line := range lines {
+			if line.Number != 0 {
+				t.Errorf("unexpected line number for %s - want: 0, got: %d", filepath.Base(path), line.Number)
+			}
+		}
+		if err := <-errChan; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLogFileFollowLineNumbers(t *testing.T) {
+	file, err := cproject.FxtFile(t, "one\ntwo\nthree\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.NewLogFile(file.Name(), cproject.WithPollInterval(10*time.Millisecond),
+		cproject.WithLineNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, errChan := logFile.Follow(ctx, 1)
+
+	receive := func() string {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return fmt.Sprintf("%d@%d %s", line.Number, line.Offset, line.Text)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a line")
+		}
+		return ""
+	}
+	if want, got := "3@8 three", receive(); want != got {
+		t.Errorf("unexpected initial line - want: %q, got: %q", want, got)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString("four\nfive\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"4@14 four", "5@19 five"} {
+		if got := receive(); want != got {
+			t.Errorf("unexpected appended line - want: %q, got: %q", want, got)
+		}
+	}
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error after cancel: %s", err)
+	}
+}
@@ -1,10 +1,59 @@
 package cproject
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 )
 
+// LineKind describes what a Line represents.
+type LineKind int
+
+const (
+	// LineText is a line of text read from the log file.
+	LineText LineKind = iota
+	// LineRotated marks that a followed log file was rotated (renamed and recreated) and the new file is being read
+	// from the beginning.
+	LineRotated
+	// LineTruncated marks that a followed log file was truncated and is being read from the beginning.
+	LineTruncated
+	// LineContext is a line of text read from the log file that didn't pass the filters but is near one that did
+	// (see WithContext).
+	LineContext
+	// LineSeparator marks a gap between lines that aren't next to each other in the log file (see WithContext).
+	LineSeparator
+)
+
+// String returns the name of the line kind.
+func (k LineKind) String() string {
+	switch k {
+	case LineRotated:
+		return "rotated"
+	case LineTruncated:
+		return "truncated"
+	case LineContext:
+		return "context"
+	case LineSeparator:
+		return "separator"
+	}
+	return "text"
+}
+
+// Line is a single line read from a log file.
+type Line struct {
+	// Text is the content of the line without the trailing newline.
+	Text string
+	// Offset is the byte offset of the start of the line in the log file.
+	Offset int64
+	// Number is the 1-based line number of the line in the log file, or 0 if it isn't known (see WithLineNumbers).
+	Number int64
+	// Kind is what the line represents; anything other than LineText or LineContext is a marker without text.
+	Kind LineKind
+	// Source is the path of the file the line was read from when a reader reads more than one file.
+	Source string
+}
+
 // LogFileReader describes the behavior of a log file that will be read.
 type LogFileReader interface {
 	// Path is the path to the log file being read.
@@ -13,15 +62,91 @@ type LogFileReader interface {
 	// YieldLines returns a string channel and an error channel for streaming lines from a log file.
 	YieldLines(int, ...Filter) (chan string, chan error)
 
+	// YieldLinesContext returns a line channel and an error channel for streaming lines from a log file. Streaming
+	// stops when the context is done.
+	YieldLinesContext(context.Context, int, ...Filter) (chan Line, chan error)
+
+	// HeadLines returns a line channel and an error channel for streaming the first lines of a log file, in the order
+	// they appear in the file. Streaming stops when the context is done.
+	HeadLines(context.Context, int, ...Filter) (chan Line, chan error)
+
+	// RangeLines returns a line channel and an error channel for streaming the lines of a part of a log file, in the
+	// order they appear in the file. Streaming stops when the context is done.
+	RangeLines(context.Context, Range, int, ...Filter) (chan Line, chan error)
+
 	// Close closes the log file.
 	Close() error
 }
 
+// LogFileFollower describes the behavior of a log file that can be followed (`tail -f`).
+type LogFileFollower interface {
+	LogFileReader
+
+	// Follow returns a line channel and an error channel for streaming the last lines of a log file followed by
+	// lines as they are appended to it. Streaming stops when the context is done.
+	Follow(context.Context, int, ...Filter) (chan Line, chan error)
+}
+
+// LogFileIdentifier describes the behavior of a log file that can identify the file it reads.
+type LogFileIdentifier interface {
+	LogFileReader
+
+	// ID identifies the file being read independent of its path.
+	ID() (FileID, error)
+}
+
+// FileID identifies a file independent of its path (by its device and inode numbers), so a file that has replaced
+// another at the same path, such as after rotation, can be told apart from it. The zero FileID is returned on
+// platforms where files can't be identified this way.
+type FileID struct {
+	Device uint64
+	Inode  uint64
+}
+
+// Order is the order lines are yielded in.
+type Order int
+
+const (
+	// OrderReverse yields lines newest first (the order they are found when reading from the end of the file).
+	OrderReverse Order = iota
+	// OrderForward yields lines in the order they appear in the log file.
+	OrderForward
+)
+
+// ParseOrder returns the Order named by the provided string. An empty string is the default order (reverse).
+func ParseOrder(s string) (Order, error) {
+	switch s {
+	case "", "reverse":
+		return OrderReverse, nil
+	case "forward":
+		return OrderForward, nil
+	}
+	return OrderReverse, fmt.Errorf("unknown order: %q", s)
+}
+
+// String returns the name of the order.
+func (o Order) String() string {
+	if o == OrderForward {
+		return "forward"
+	}
+	return "reverse"
+}
+
 // LogFile works with logs in a very basic way. It's capable of reading the entire contents of the file and tailing
 // `n` lines of the log file.
 type LogFile struct {
-	path string
-	file *os.File
+	path         string
+	file         *os.File
+	order        Order
+	pollInterval time.Duration
+	since        time.Time
+	until        time.Time
+	recordStart  Filter
+	before       int
+	after        int
+	lineNumbers  bool
+	end          int64
+	followFrom   int64
 }
 
 type logFileOpt func(*LogFile)
@@ -33,10 +158,88 @@ func WithFile(file *os.File) logFileOpt {
 	}
 }
 
+// WithOrder is a LogFile option that sets the order lines are yielded in.
+func WithOrder(order Order) logFileOpt {
+	return func(lf *LogFile) {
+		lf.order = order
+	}
+}
+
+// WithPollInterval is a LogFile option that sets how often a followed file is checked for appended lines.
+func WithPollInterval(d time.Duration) logFileOpt {
+	return func(lf *LogFile) {
+		lf.pollInterval = d
+	}
+}
+
+// WithTimeRange is a LogFile option that limits the lines read to those with timestamps (see ParseTimestamp) from
+// `since` to `until` (inclusive). A zero `since` or `until` leaves that end of the range open. Lines without a
+// timestamp go with the line before them.
+func WithTimeRange(since, until time.Time) logFileOpt {
+	return func(lf *LogFile) {
+		lf.since = since
+		lf.until = until
+	}
+}
+
+// WithRecordStart is a LogFile option that groups lines into multi-line records, such as a log line and the stack
+// trace after it. Lines included by the `start` filter (e.g. a MatchRegexp for the timestamp at the start of a line,
+// or Unindented) start a record and the lines after them that aren't included continue it. Records are read as one
+// line with the lines of the record joined by newlines: filters are applied to whole records and the number of lines
+// read counts records. A nil `start` doesn't group lines.
+func WithRecordStart(start Filter) logFileOpt {
+	return func(lf *LogFile) {
+		lf.recordStart = start
+	}
+}
+
+// WithContext is a LogFile option that reads up to `before` lines before and `after` lines after each line that
+// passes the filters (like `grep -B -A`). The lines around them are read as LineContext lines and a LineSeparator
+// marks each gap between lines that aren't next to each other. The number of lines read counts the lines that pass
+// the filters. Readers of more than one log file don't read context lines.
+func WithContext(before, after int) logFileOpt {
+	return func(lf *LogFile) {
+		lf.before = before
+		lf.after = after
+	}
+}
+
+// WithLineNumbers is a LogFile option that sets whether the lines read are numbered (see Line). Counting the lines
+// before a line means reading everything before it, so lines far from the start of the file, or from the line
+// numbered before them, aren't numbered.
+func WithLineNumbers(numbered bool) logFileOpt {
+	return func(lf *LogFile) {
+		lf.lineNumbers = numbered
+	}
+}
+
+// WithEnd is a LogFile option that reads only the lines before the byte offset `end`, which is expected to be the
+// start of a line (e.g. the offset of the oldest line read before, to page back through the log file). A negative
+// `end` reads to the end of the file. The end doesn't apply to followed log files.
+func WithEnd(end int64) logFileOpt {
+	return func(lf *LogFile) {
+		lf.end = end
+	}
+}
+
+// WithFollowFrom is a LogFile option that resumes following the log file at the byte offset `from` (e.g. just past
+// the last line a client received before it reconnected): lines that start at or after it are read, instead of the last
+// lines of the file, and then lines as they are appended. An offset past the end of the file is taken as the file
+// having been truncated, except for one just past an unterminated last line, which is where the line would end with a
+// newline. A negative `from` follows from the last lines of the file.
+func WithFollowFrom(from int64) logFileOpt {
+	return func(lf *LogFile) {
+		lf.followFrom = from
+	}
+}
+
 // NewLogFile creates a new LogFile and applies the provided options.
 func NewLogFile(path string, opts ...logFileOpt) (*LogFile, error) {
 	lf := &LogFile{
-		path: path,
+		path:         path,
+		pollInterval: DefaultPollInterval,
+		end:          endOfFile,
+		followFrom:   endOfFile,
 	}
 
 	for _, opt := range opts {
@@ -49,15 +252,11 @@ func NewLogFile(path string, opts ...logFileOpt) (*LogFile, error) {
 
 	fh, err := os.Open(path)
 	if err != nil {
-		if os.IsNotExist(err) || os.IsPermission(err) {
-			return nil, fmt.Errorf("error opening file: %w", err)
-		}
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
+	lf.file = fh
 
-	return &LogFile{
-		path: path,
-		file: fh,
-	}, nil
+	return lf, nil
 }
 
 // Path is the path to the log file being read.
@@ -65,12 +264,207 @@ func (f *LogFile) Path() string {
 	return f.path
 }
 
+// ID identifies the file being read independent of its path.
+func (l *LogFile) ID() (FileID, error) {
+	info, err := l.file.Stat()
+	if err != nil {
+		return FileID{}, err
+	}
+	return fileID(info), nil
+}
+
+// hasContext returns true if the lines around the lines that pass the filters are read too.
+func (l *LogFile) hasContext() bool {
+	return l.before > 0 || l.after > 0
+}
+
+// hasTimeRange returns true if the lines read are limited to a time range.
+func (l *LogFile) hasTimeRange() bool {
+	return !l.since.IsZero() || !l.until.IsZero()
+}
+
+// span returns the span of the log file that is read: the lines in its time range (see timeSpan) before its end.
+func (l *LogFile) span() (lineSpan, error) {
+	within, err := timeSpan(l.file, l.since, l.until)
+	if err != nil {
+		return within, err
+	}
+	if l.end >= 0 && (within.end < 0 || l.end < within.end) {
+		within.end = l.end
+	}
+	return within, nil
+}
+
+// numbered numbers the lines passed to `fn` if the log file has line numbers. The line at `offset` is line `number`,
+// if it's known, to count from. It returns the function to pass lines to and a function that returns the error
+// encountered numbering them.
+func (l *LogFile) numbered(offset, number int64, fn func(Line) bool) (func(Line) bool, func() error) {
+	if !l.lineNumbers {
+		return fn, func() error { return nil }
+	}
+	counter := newLineCounter(l.file)
+	if number > 0 {
+		counter.offset, counter.number = offset, number
+	}
+	return counter.numbered(fn), func() error { return counter.err }
+}
+
+// tailLines reads up to `numLines` lines from the end of the log file, or the end of its time range, and passes them
+// to `fn` in the configured order, numbering them if the log file has line numbers.
+func (l *LogFile) tailLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	fn, numberErr := l.numbered(0, 0, fn)
+	if err := l.readLines(ctx, numLines, filters, fn); err != nil {
+		return err
+	}
+	return numberErr()
+}
+
+// headLines reads up to `numLines` lines from the start of the log file, or the start of its time range, and passes
+// them to `fn` in the order they appear. Lines are grouped into records if the log file has a record start but
+// context lines aren't read.
+func (l *LogFile) headLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	within, err := l.span()
+	if err != nil {
+		return err
+	}
+	fn, numberErr := l.numbered(0, 0, fn)
+	if err := readForward(ctx, l.file, within, numLines, filters, l.recordStart, fn); err != nil {
+		return err
+	}
+	return numberErr()
+}
+
+// rangeLines reads up to `numLines` lines in a range of the log file, and its time range, and passes them to `fn` in
+// the order they appear. Lines are grouped into records if the log file has a record start but context lines aren't
+// read.
+func (l *LogFile) rangeLines(ctx context.Context, rng Range, numLines int, filters []Filter, fn func(Line) bool) error {
+	within, err := l.span()
+	if err != nil {
+		return err
+	}
+	span, number, err := rng.span(l.file)
+	if err != nil {
+		return err
+	}
+	fn, numberErr := l.numbered(span.start, number, fn)
+	if span.start < within.start {
+		span.start = within.start
+	}
+	if within.end >= 0 && (span.end < 0 || within.end < span.end) {
+		span.end = within.end
+	}
+	if err := readForward(ctx, l.file, span, numLines, filters, l.recordStart, fn); err != nil {
+		return err
+	}
+	return numberErr()
+}
+
+// readLines reads up to `numLines` lines from the end of the log file, or the end of its time range, and passes them
+// to `fn` in the configured order. The time range is found by binary searching the file for the first and last
+// timestamps in range, so the lines outside of it are never read. Lines are grouped into records if the log file
+// has a record start and the lines around the lines that pass the filters are read too if it has context.
+func (l *LogFile) readLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	within, err := l.span()
+	if err != nil {
+		return err
+	}
+	if !l.hasContext() {
+		if l.recordStart != nil {
+			return tailRecords(ctx, l.file, within, numLines, filters, l.order, l.recordStart, fn)
+		}
+		return tailLines(ctx, l.file, within, numLines, filters, l.order, fn)
+	}
+
+	// every line (or record) is read, newest first, to find the context of the lines that pass the filters.
+	gathered := []Line{}
+	emit := fn
+	if l.order == OrderForward {
+		emit = func(line Line) bool {
+			gathered = append(gathered, line)
+			return true
+		}
+	}
+	lines := newReverseContext(filters, l.before, l.after, numLines, emit)
+	if l.recordStart != nil {
+		err = tailRecords(ctx, l.file, within, 0, nil, OrderReverse, l.recordStart, lines.add)
+	} else {
+		err = tailLines(ctx, l.file, within, 0, nil, OrderReverse, lines.add)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := len(gathered) - 1; i >= 0; i-- {
+		if !fn(gathered[i]) {
+			break
+		}
+	}
+	return ctx.Err()
+}
+
 // YieldLines returns a string channel and an error channel for streaming lines from a log file.
 func (l *LogFile) YieldLines(numLines int, filters ...Filter) (chan string, chan error) {
+	if l.hasTimeRange() || l.recordStart != nil || l.hasContext() || l.end >= 0 {
+		return streamText(func(ctx context.Context, fn func(Line) bool) error {
+			return l.tailLines(ctx, numLines, filters, fn)
+		})
+	}
+
 	lines := make(chan string, 1)
 	errChan := make(chan error, 1)
 
-	go yieldLines(l.file, numLines, filters, lines, errChan)
+	if l.order == OrderForward {
+		go yieldLinesForward(l.file, numLines, filters, lines, errChan)
+	} else {
+		go yieldLines(l.file, numLines, filters, lines, errChan)
+	}
+
+	return lines, errChan
+}
+
+// YieldLinesContext returns a line channel and an error channel for streaming lines from a log file. Streaming stops,
+// and the context's error is sent on the error channel, when the context is done - even if nothing is reading the
+// line channel.
+func (l *LogFile) YieldLinesContext(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return l.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// HeadLines returns a line channel and an error channel for streaming the first `numLines` lines of a log file (or of
+// its time range) that pass the filters, in the order they appear in the file. Context lines aren't read (see
+// WithContext). Streaming stops, and the context's error is sent on the error channel, when the context is done.
+func (l *LogFile) HeadLines(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return l.headLines(ctx, numLines, filters, fn)
+	})
+}
+
+// RangeLines returns a line channel and an error channel for streaming up to `numLines` lines of a range of a log file
+// (see Range) that pass the filters, in the order they appear in the file. The range is limited to the time range of
+// the log file, if it has one. Context lines aren't read (see WithContext). Streaming stops, and the context's error is
+// sent on the error channel, when the context is done.
+func (l *LogFile) RangeLines(ctx context.Context, rng Range, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		if err := rng.Validate(); err != nil {
+			return err
+		}
+		return l.rangeLines(ctx, rng, numLines, filters, fn)
+	})
+}
+
+// Follow returns a line channel and an error channel for streaming the last `numLines` lines of a log file, in the
+// order they appear in the file, followed by lines as they are appended to it. With WithFollowFrom, the lines from an
+// offset on are streamed instead of the last lines. Rotation and truncation of the file are followed and announced
+// with marker lines. The end of a time range (see WithTimeRange) doesn't apply to followed log files, lines aren't
+// grouped into records (see WithRecordStart) and context lines aren't read (see WithContext). Streaming stops when
+// the context is done.
+func (l *LogFile) Follow(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	lines := make(chan Line, 1)
+	errChan := make(chan error, 1)
+
+	go followLines(ctx, l.path, l.file, numLines, filters, l.since, l.followFrom, l.pollInterval, l.lineNumbers,
+		lines, errChan)
 
 	return lines, errChan
 }
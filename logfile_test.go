@@ -1,9 +1,12 @@
 package cproject_test
 
 import (
+	"context"
 	"errors"
 	"os"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/marklap/cproject"
 )
@@ -214,3 +217,256 @@ func TestClose(t *testing.T) {
 	}
 
 }
+
+func TestParseOrder(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		s       string
+		want    cproject.Order
+		wantErr bool
+	}{
+		{desc: "default", s: "", want: cproject.OrderReverse},
+		{desc: "reverse", s: "reverse", want: cproject.OrderReverse},
+		{desc: "forward", s: "forward", want: cproject.OrderForward},
+		{desc: "unknown", s: "sideways", wantErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, err := cproject.ParseOrder(tC.s)
+			if (err != nil) != tC.wantErr {
+				t.Fatalf("unexpected error - want error: %t, got: %v", tC.wantErr, err)
+			}
+			if tC.want != got {
+				t.Errorf("unexpected order - want: %s, got: %s", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestLogFileYieldLinesForward(t *testing.T) {
+	content := cproject.FxtContent()
+	file, err := cproject.FxtFile(t, content)
+	if err != nil {
+		t.Error(err)
+	}
+
+	logFile, err := cproject.NewLogFile(file.Name(), cproject.WithFile(file), cproject.WithOrder(cproject.OrderForward))
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines, errChan := logFile.YieldLines(2)
+
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	err = <-errChan
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := []string{"naming things,", "and off-by-1 errors."}
+	if !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+}
+
+// waitForGoroutines waits for the number of running goroutines to drop to `want`, failing the test if it doesn't.
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked - want: %d, got: %d", want, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLogFileYieldLinesContext(t *testing.T) {
+	content := cproject.FxtContent()
+	file, err := cproject.FxtFile(t, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logFile, err := cproject.FxtLogFile(file.Name(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, errChan := logFile.YieldLinesContext(context.Background(), 2)
+
+	var got []string
+	for line := range lines {
+		got = append(got, line.Text)
+	}
+	if err := <-errChan; err != nil {
+		t.Error(err)
+	}
+
+	want := []string{"and off-by-1 errors.", "naming things,"}
+	if !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestLogFileYieldLinesContextCancel(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		order cproject.Order
+	}{
+		{desc: "reverse", order: cproject.OrderReverse},
+		{desc: "forward", order: cproject.OrderForward},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+
+			logFile, err := cproject.NewLogFile("./testdata/number_lines.txt", cproject.WithOrder(tC.order))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			lines, errChan := logFile.YieldLinesContext(ctx, 0)
+
+			// read one line then walk away from the channel like a disconnected client.
+			<-lines
+			cancel()
+
+			select {
+			case err := <-errChan:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("unexpected error - want: %s, got: %v", context.Canceled, err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the scan to stop")
+			}
+
+			if err := logFile.Close(); err != nil {
+				t.Errorf("unexpected error closing file: %s", err)
+			}
+			waitForGoroutines(t, baseline)
+		})
+	}
+}
+
+func TestLogFileYieldLinesContextCancelFiltered(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	logFile, err := cproject.NewLogFile("./testdata/number_lines.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	// nothing matches, so the scan never gets to hand a line to the consumer.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	filter := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"no such line"}))
+	lines, errChan := logFile.YieldLinesContext(ctx, 10, filter)
+
+	for range lines {
+		t.Error("unexpected line yielded")
+	}
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error - want: %s, got: %v", context.Canceled, err)
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestLogFileWithEnd(t *testing.T) {
+	// ends are the offsets of the lines of the context fixture.
+	ends := []int64{}
+	offset := int64(0)
+	for _, line := range fxtContextLines {
+		ends = append(ends, offset)
+		offset += int64(len(line)) + 1
+	}
+
+	testCases := []struct {
+		desc  string
+		end   int64
+		lines int
+		order cproject.Order
+		want  []string
+	}{
+		{
+			desc:  "noEnd",
+			end:   -1,
+			lines: 2,
+			want:  []string{fxtContextLines[7], fxtContextLines[6]},
+		}, {
+			desc:  "page",
+			end:   ends[6],
+			lines: 2,
+			want:  []string{fxtContextLines[5], fxtContextLines[4]},
+		}, {
+			desc:  "pageForward",
+			end:   ends[6],
+			lines: 2,
+			order: cproject.OrderForward,
+			want:  []string{fxtContextLines[4], fxtContextLines[5]},
+		}, {
+			desc:  "lastPage",
+			end:   ends[2],
+			lines: 10,
+			want:  []string{fxtContextLines[1], fxtContextLines[0]},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order), cproject.WithEnd(tC.end))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				lines, errChan := logFile.YieldLines(tC.lines)
+
+				var got []string
+				for line := range lines {
+					got = append(got, line)
+				}
+				if err := <-errChan; err != nil {
+					t.Error(err)
+				}
+
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", path, tC.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLogFileID(t *testing.T) {
+	plain, compressed := FxtContextFile(t)
+
+	ids := []cproject.FileID{}
+	for _, path := range []string{plain, plain, compressed} {
+		logFile, err := cproject.OpenLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logFile.Close()
+
+		id, err := logFile.(cproject.LogFileIdentifier).ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if ids[0] != ids[1] {
+		t.Errorf("unexpected IDs of the same file - %v != %v", ids[0], ids[1])
+	}
+	if runtime.GOOS != "windows" && ids[0] == ids[2] {
+		t.Errorf("unexpected IDs of different files - %v == %v", ids[0], ids[2])
+	}
+}
@@ -0,0 +1,77 @@
+package cproject
+
+import (
+	"strconv"
+	"strings"
+)
+
+// LogfmtParser parses logfmt lines: space separated `key=value` pairs where values with spaces are double quoted
+// (e.g. `ts=2024-02-20T07:10:42Z level=warn msg="disk almost full"`). Values are kept as strings and a key without a
+// value is true. A line is only logfmt if it has at least one `key=value` pair.
+type LogfmtParser struct{}
+
+// Parse parses the fields of a line. If the line isn't logfmt, false is returned.
+func (LogfmtParser) Parse(line string) (Fields, bool) {
+	fields := Fields{}
+	pairs := 0
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		// the key runs up to an equals sign or the next space.
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, false
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, false
+		}
+		if i == len(line) || line[i] != '=' {
+			fields[key] = true
+			continue
+		}
+		i++
+
+		value, n, ok := logfmtValue(line[i:])
+		if !ok {
+			return nil, false
+		}
+		fields[key] = value
+		pairs++
+		i += n
+	}
+	return fields, pairs > 0
+}
+
+// logfmtValue reads the value at the start of `s`, unquoting it if it's quoted. It returns the value and the number
+// of bytes it took up. If a quoted value isn't terminated, false is returned.
+func logfmtValue(s string) (string, int, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], end, true
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				// not a valid Go string (e.g. an unknown escape); take the quoted bytes as they are.
+				value = s[1:i]
+			}
+			return value, i + 1, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,92 @@
+package cproject_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestLogfmtParser(t *testing.T) {
+	testCases := []struct {
+		desc string
+		line string
+		want cproject.Fields
+	}{
+		{
+			desc: "pairs",
+			line: "ts=2024-02-20T07:10:42Z level=warn status=503",
+			want: cproject.Fields{"ts": "2024-02-20T07:10:42Z", "level": "warn", "status": "503"},
+		}, {
+			desc: "quotedValue",
+			line: `level=warn msg="disk almost \"full\"" path=/var`,
+			want: cproject.Fields{"level": "warn", "msg": `disk almost "full"`, "path": "/var"},
+		}, {
+			desc: "emptyValues",
+			line: `level= msg=""`,
+			want: cproject.Fields{"level": "", "msg": ""},
+		}, {
+			desc: "bareKey",
+			line: "level=error  retrying",
+			want: cproject.Fields{"level": "error", "retrying": true},
+		}, {
+			desc: "dottedKey",
+			line: "user.id=42",
+			want: cproject.Fields{"user.id": "42"},
+		}, {
+			desc: "plainText",
+			line: "2024-02-20T07:10:42Z ERROR GET /healthcheck 503",
+		}, {
+			desc: "unterminatedQuote",
+			line: `level=warn msg="disk almost`,
+		}, {
+			desc: "missingKey",
+			line: "=warn",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := cproject.LogfmtParser{}.Parse(tC.line)
+			if ok != (tC.want != nil) {
+				t.Fatalf("unexpected parse result - want: %t, got: %t (%#v)", tC.want != nil, ok, got)
+			}
+			if ok && !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("unexpected fields - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestLogfmtMatchField(t *testing.T) {
+	fxtLine := `ts=2024-02-20T07:10:42Z level=warn status=503 msg="upstream timed out"`
+	testCases := []struct {
+		desc      string
+		predicate cproject.FieldPredicate
+		want      bool
+	}{
+		{
+			desc:      "level",
+			predicate: cproject.FieldPredicate{Field: "level", Op: cproject.OpEq, Value: "warn"},
+			want:      true,
+		}, {
+			desc:      "statusAsNumber",
+			predicate: cproject.FieldPredicate{Field: "status", Op: cproject.OpGe, Value: 500.0},
+			want:      true,
+		}, {
+			desc:      "quotedValue",
+			predicate: cproject.FieldPredicate{Field: "msg", Op: cproject.OpEq, Value: "upstream timed out"},
+			want:      true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			filter, err := cproject.NewMatchField(cproject.LogfmtParser{}, tC.predicate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.Include(fxtLine); tC.want != got {
+				t.Errorf("failure to including line - want: %t, got: %t", tC.want, got)
+			}
+		})
+	}
+}
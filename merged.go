@@ -0,0 +1,291 @@
+package cproject
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxTime sorts after any timestamp parsed from a log line.
+var maxTime = time.Unix(math.MaxInt64/2, 0)
+
+const (
+	// maxPendingLines is the most lines without a timestamp that are held back waiting for a line with one.
+	maxPendingLines = 1024
+)
+
+// mergeSource is one of the log files being merged along with the line at the head of its stream.
+type mergeSource struct {
+	logFile LogFileReader
+	lines   chan Line
+	errChan chan error
+	// forward is true when lines are read in the order they appear in the file rather than newest first.
+	forward bool
+	// pending are lines that have been read but not yet moved to the head.
+	pending []Line
+	// next is the line with a timestamp read after the pending lines in forward order, if there is one.
+	next *Line
+	// head is the next line from the source that hasn't been passed along yet.
+	head Line
+	// ts is the timestamp of head.
+	ts   time.Time
+	done bool
+}
+
+// newMergeSource creates a mergeSource of the lines streamed from a log file, read newest first or, if `forward` is
+// true, in the order they appear in the file.
+func newMergeSource(logFile LogFileReader, lines chan Line, errChan chan error, forward bool) *mergeSource {
+	source := &mergeSource{logFile: logFile, lines: lines, errChan: errChan, forward: forward, ts: maxTime}
+	if forward {
+		// lines before the first timestamp in the file come before everything else.
+		source.ts = time.Time{}
+	}
+	return source
+}
+
+// fill reads lines, newest first, up to and including the next line with a timestamp. Lines without a timestamp
+// (e.g. a stack trace) belong to the line with a timestamp before them in the file, so they all share its timestamp.
+// If no line with a timestamp turns up, the lines keep the current timestamp. In forward order the lines without a
+// timestamp come after their line, so lines are read up to the next line with a timestamp, which is held back.
+func (s *mergeSource) fill() error {
+	if s.forward {
+		return s.fillForward()
+	}
+	for len(s.pending) < maxPendingLines {
+		line, ok := <-s.lines
+		if !ok {
+			return <-s.errChan
+		}
+		line.Source = s.logFile.Path()
+		s.pending = append(s.pending, line)
+		if ts, ok := ParseTimestamp(line.Text); ok {
+			s.ts = ts
+			return nil
+		}
+	}
+	return nil
+}
+
+// fillForward reads lines, in the order they appear in the file, from the line with a timestamp held back by the last
+// fill up to (not including) the next one.
+func (s *mergeSource) fillForward() error {
+	if s.next != nil {
+		s.ts, _ = ParseTimestamp(s.next.Text)
+		s.pending = append(s.pending, *s.next)
+		s.next = nil
+	}
+	for len(s.pending) < maxPendingLines {
+		line, ok := <-s.lines
+		if !ok {
+			return <-s.errChan
+		}
+		line.Source = s.logFile.Path()
+		if ts, ok := ParseTimestamp(line.Text); ok {
+			if len(s.pending) > 0 {
+				s.next = &line
+				return nil
+			}
+			s.ts = ts
+		}
+		s.pending = append(s.pending, line)
+	}
+	return nil
+}
+
+// advance moves the head of the source to its next line. An error is returned if the source failed.
+func (s *mergeSource) advance() error {
+	if len(s.pending) == 0 {
+		if err := s.fill(); err != nil {
+			return err
+		}
+		if len(s.pending) == 0 {
+			s.done = true
+			return nil
+		}
+	}
+	s.head, s.pending = s.pending[0], s.pending[1:]
+	return nil
+}
+
+// MergedLogFile reads several log files as one logical log file. The last (or first) lines of each file are
+// interleaved by the timestamps parsed from them (see ParseTimestamp) and each line is tagged with the path of the
+// file it was read from. Lines without a timestamp stay with the line before them in their file.
+type MergedLogFile struct {
+	logFiles []LogFileReader
+	order    Order
+}
+
+// NewMergedLogFile creates a new MergedLogFile of the log files at `paths` and applies the provided options to each
+// of them. Compressed log files are detected by their magic bytes.
+func NewMergedLogFile(paths []string, opts ...logFileOpt) (*MergedLogFile, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no log files to merge")
+	}
+
+	// the order applies to the merged lines, so pull it out of the options. Context lines aren't read from more than
+	// one log file and the end of one file isn't the end of the others.
+	cfg := &LogFile{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	opts = append(append([]logFileOpt{}, opts...), WithOrder(OrderReverse), WithContext(0, 0),
+		WithEnd(endOfFile))
+
+	mf := &MergedLogFile{order: cfg.order}
+	for _, path := range paths {
+		logFile, err := OpenLogFile(path, opts...)
+		if err != nil {
+			mf.Close()
+			return nil, err
+		}
+		mf.logFiles = append(mf.logFiles, logFile)
+	}
+	return mf, nil
+}
+
+// Path is the paths of the log files being read, separated by the OS path list separator.
+func (f *MergedLogFile) Path() string {
+	return strings.Join(f.Paths(), string(os.PathListSeparator))
+}
+
+// Paths are the paths of the log files being read.
+func (f *MergedLogFile) Paths() []string {
+	paths := make([]string, 0, len(f.logFiles))
+	for _, logFile := range f.logFiles {
+		paths = append(paths, logFile.Path())
+	}
+	return paths
+}
+
+// tailLines reads up to `numLines` lines from the end of the merged log files and passes them to `fn` in the
+// configured order. Reading stops when `fn` returns false.
+func (f *MergedLogFile) tailLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	// lines in forward order have to be gathered (newest first) before they can be passed along oldest first.
+	gathered := []Line{}
+	collect := fn
+	if f.order == OrderForward {
+		collect = func(line Line) bool {
+			gathered = append(gathered, line)
+			return true
+		}
+	}
+
+	err := f.merge(ctx, numLines, false, func(ctx context.Context, logFile LogFileReader) (chan Line, chan error) {
+		return logFile.YieldLinesContext(ctx, numLines, filters...)
+	}, collect)
+	if err != nil {
+		return err
+	}
+
+	for i := len(gathered) - 1; i >= 0; i-- {
+		if !fn(gathered[i]) {
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+// headLines reads up to `numLines` lines from the start of the merged log files and passes them to `fn` in the order
+// of their timestamps. Reading stops when `fn` returns false.
+func (f *MergedLogFile) headLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	return f.merge(ctx, numLines, true, func(ctx context.Context, logFile LogFileReader) (chan Line, chan error) {
+		return logFile.HeadLines(ctx, numLines, filters...)
+	}, fn)
+}
+
+// merge interleaves up to `numLines` lines streamed from each of the log files by `yield` and passes them to `fn`.
+// Lines are streamed newest first and passed along newest first or, if `forward` is true, streamed in the order they
+// appear in their files and passed along oldest first. Reading stops when `fn` returns false.
+func (f *MergedLogFile) merge(ctx context.Context, numLines int, forward bool,
+	yield func(context.Context, LogFileReader) (chan Line, chan error), fn func(Line) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	sources := make([]*mergeSource, 0, len(f.logFiles))
+	defer func() {
+		// wait for every source to wind down before the files are closed.
+		cancel()
+		for _, source := range sources {
+			for range source.lines {
+			}
+		}
+	}()
+
+	for _, logFile := range f.logFiles {
+		lines, errChan := yield(ctx, logFile)
+		source := newMergeSource(logFile, lines, errChan, forward)
+		sources = append(sources, source)
+		if err := source.advance(); err != nil {
+			return err
+		}
+	}
+
+	for count := 0; numLines <= 0 || count < numLines; count++ {
+		// the newest head is next (or the oldest in forward order); ties go to the source listed first.
+		var next *mergeSource
+		for _, source := range sources {
+			if source.done {
+				continue
+			}
+			if next == nil || (!forward && source.ts.After(next.ts)) || (forward && source.ts.Before(next.ts)) {
+				next = source
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		if !fn(next.head) {
+			return ctx.Err()
+		}
+		if err := next.advance(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// YieldLines returns a string channel and an error channel for streaming lines from a log file.
+func (f *MergedLogFile) YieldLines(numLines int, filters ...Filter) (chan string, chan error) {
+	return streamText(func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// YieldLinesContext returns a line channel and an error channel for streaming lines from a log file. Streaming stops,
+// and the context's error is sent on the error channel, when the context is done.
+func (f *MergedLogFile) YieldLinesContext(ctx context.Context, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// HeadLines returns a line channel and an error channel for streaming the first `numLines` lines across all of the log
+// files, interleaved oldest first by their timestamps. Streaming stops, and the context's error is sent on the error
+// channel, when the context is done.
+func (f *MergedLogFile) HeadLines(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.headLines(ctx, numLines, filters, fn)
+	})
+}
+
+// RangeLines sends an error on the error channel; ranges can't be read from more than one log file.
+func (f *MergedLogFile) RangeLines(ctx context.Context, rng Range, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return errRangeMultipleFiles
+	})
+}
+
+// Close closes all of the log files.
+func (f *MergedLogFile) Close() error {
+	var firstErr error
+	for _, logFile := range f.logFiles {
+		if err := logFile.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,107 @@
+package cproject_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+// FxtMergeSet creates an nginx, app and worker log in a temporary directory and returns their paths.
+func FxtMergeSet(t *testing.T) []string {
+	t.Helper()
+	dir := t.TempDir()
+	files := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "nginx.log",
+			content: `10.0.0.1 - - [20/Feb/2024:07:10:40 +0000] "GET /checkout HTTP/1.1" 200 12` + "\n" +
+				`10.0.0.1 - - [20/Feb/2024:07:10:44 +0000] "POST /checkout HTTP/1.1" 500 0` + "\n",
+		}, {
+			name: "app.log",
+			content: "2024-02-20T07:10:41Z checkout started\n" +
+				"2024-02-20T07:10:43Z checkout failed\n" +
+				"  at Checkout.pay()\n",
+		}, {
+			name:    "worker.log",
+			content: "2024-02-20T07:10:42Z charge declined\n",
+		},
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestMergedLogFileYieldLinesContext(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		lines int
+		order cproject.Order
+		want  []string
+	}{
+		{
+			desc:  "lastThree",
+			lines: 3,
+			want: []string{
+				"nginx.log:" + `10.0.0.1 - - [20/Feb/2024:07:10:44 +0000] "POST /checkout HTTP/1.1" 500 0`,
+				"app.log:  at Checkout.pay()",
+				"app.log:2024-02-20T07:10:43Z checkout failed",
+			},
+		}, {
+			desc:  "allForward",
+			lines: 0,
+			order: cproject.OrderForward,
+			want: []string{
+				"nginx.log:" + `10.0.0.1 - - [20/Feb/2024:07:10:40 +0000] "GET /checkout HTTP/1.1" 200 12`,
+				"app.log:2024-02-20T07:10:41Z checkout started",
+				"worker.log:2024-02-20T07:10:42Z charge declined",
+				"app.log:2024-02-20T07:10:43Z checkout failed",
+				"app.log:  at Checkout.pay()",
+				"nginx.log:" + `10.0.0.1 - - [20/Feb/2024:07:10:44 +0000] "POST /checkout HTTP/1.1" 500 0`,
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			paths := FxtMergeSet(t)
+
+			logFile, err := cproject.NewMergedLogFile(paths, cproject.WithOrder(tC.order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logFile.Close()
+
+			lines, errChan := logFile.YieldLinesContext(context.Background(), tC.lines)
+
+			var got []string
+			for line := range lines {
+				got = append(got, filepath.Base(line.Source)+":"+line.Text)
+			}
+			if err := <-errChan; err != nil {
+				t.Error(err)
+			}
+
+			if !cproject.StringSlicesEqual(tC.want, got) {
+				t.Errorf("unexpected results - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestNewMergedLogFileMissing(t *testing.T) {
+	paths := append(FxtMergeSet(t), "this-file-does-not-exist.missing")
+	if _, err := cproject.NewMergedLogFile(paths); err == nil {
+		t.Errorf("no error returned - expected error for a missing file")
+	}
+}
@@ -0,0 +1,189 @@
+package cproject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errRangeMultipleFiles is the error for a range read of more than one log file; offsets and line numbers are only
+// meaningful within a single file.
+var errRangeMultipleFiles = errors.New("ranges can not be read from more than one log file")
+
+// RangeUnit is the unit of the start and end of a Range.
+type RangeUnit int
+
+const (
+	// RangeBytes is a range of byte offsets.
+	RangeBytes RangeUnit = iota
+	// RangeLines is a range of line numbers, counting from 1 (see Line).
+	RangeLines
+)
+
+// ParseRangeUnit returns the RangeUnit named by the provided string. An empty string is the default unit (bytes).
+func ParseRangeUnit(s string) (RangeUnit, error) {
+	switch s {
+	case "", "bytes":
+		return RangeBytes, nil
+	case "lines":
+		return RangeLines, nil
+	}
+	return RangeBytes, fmt.Errorf("unknown range unit: %q", s)
+}
+
+// String returns the name of the range unit.
+func (u RangeUnit) String() string {
+	if u == RangeLines {
+		return "lines"
+	}
+	return "bytes"
+}
+
+// Range is a part of a log file to read, from `Start` up to (not including) `End`. A byte range is the lines that
+// start at offsets in the range; a line that starts before `Start` isn't read even if it ends in the range, and a line
+// that starts before `End` is read whole. A line range is the lines with line numbers in the range. An `End` of 0 or
+// less is the end of the file.
+type Range struct {
+	Unit  RangeUnit
+	Start int64
+	End   int64
+}
+
+// Validate returns an error if the range can't be read.
+func (r Range) Validate() error {
+	switch {
+	case r.Unit == RangeLines && r.Start < 1:
+		return fmt.Errorf("range start (%d) must be at least 1 for lines", r.Start)
+	case r.Start < 0:
+		return fmt.Errorf("range start (%d) can not be negative", r.Start)
+	case r.End > 0 && r.End < r.Start:
+		return fmt.Errorf("range end (%d) is before its start (%d)", r.End, r.Start)
+	}
+	return nil
+}
+
+// String returns the range in `unit:start-end` form; an open end is left out.
+func (r Range) String() string {
+	if r.End <= 0 {
+		return fmt.Sprintf("%s:%d-", r.Unit, r.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", r.Unit, r.Start, r.End)
+}
+
+// contains returns true if a line with the provided offset and line number is in the range, and false and whether
+// the lines after it are past the range if it isn't.
+func (r Range) contains(offset, number int64) (bool, bool) {
+	at := offset
+	if r.Unit == RangeLines {
+		at = number
+	}
+	if r.End > 0 && at >= r.End {
+		return false, true
+	}
+	return at >= r.Start, false
+}
+
+// span returns the span of the lines of the provided file in the range, along with the line number of the first of
+// them, or 0 if it isn't known. Finding a line range means counting the lines before it.
+func (r Range) span(file *os.File) (lineSpan, int64, error) {
+	if r.Unit == RangeBytes {
+		start, err := lineStart(file, r.Start)
+		if err != nil {
+			return lineSpan{}, 0, err
+		}
+		span := lineSpan{start: start, end: endOfFile}
+		if r.End > 0 {
+			span.end = r.End
+		}
+		return span, 0, nil
+	}
+
+	// the lines before the range are scanned, numbered from 1, until the offsets of the start and end are found.
+	span := lineSpan{start: -1, end: endOfFile}
+	number := int64(1)
+	size := int64(0)
+	err := scanForward(file, wholeFile, func(line string, offset int64) bool {
+		if number == r.Start {
+			span.start = offset
+		}
+		if number == r.End {
+			span.end = offset
+			return false
+		}
+		number++
+		size = offset + int64(len(line)) + 1
+		return true
+	})
+	if err != nil {
+		return lineSpan{}, 0, err
+	}
+	if span.start < 0 {
+		// the range starts after the last line.
+		span.start = size
+	}
+	return span, r.Start, nil
+}
+
+// lineStart returns the offset of the first line of the provided file that starts at or after `offset`.
+func lineStart(file *os.File, offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	start := offset
+	err := scanForward(file, lineSpan{start: offset - 1, end: endOfFile}, func(line string, lineOffset int64) bool {
+		// the first "line" is the rest of the line the byte before `offset` is in.
+		start = lineOffset + int64(len(line)) + 1
+		return false
+	})
+	return start, err
+}
+
+// readForward reads the lines of the provided file that start in the part of it described by `within` in the order
+// they appear and passes up to `numLines` lines that pass the filters to `fn`. If `numLines` is 0 or less, all lines
+// are read. Lines are grouped into records if `start` isn't nil; the filters are applied to whole records and
+// `numLines` counts records. Empty lines aren't passed along. Reading stops when `fn` returns false. If the context is
+// done before reading finishes, the context's error is returned.
+func readForward(ctx context.Context, file *os.File, within lineSpan, numLines int, filters []Filter, start Filter,
+	fn func(Line) bool) error {
+	var records *forwardRecords
+	if start != nil {
+		records = &forwardRecords{start: start}
+	}
+	count := 0
+	stopped := false
+	emit := func(line Line) bool {
+		if !passesFilters(line.Text, filters) {
+			return true
+		}
+		count++
+		stopped = !fn(line) || (numLines > 0 && count >= numLines)
+		return !stopped
+	}
+
+	err := scanForward(file, lineSpan{start: within.start, end: endOfFile}, func(text string, offset int64) bool {
+		if ctx.Err() != nil || (within.end >= 0 && offset >= within.end) {
+			return false
+		}
+		if text == "" {
+			return true
+		}
+		line := Line{Text: text, Offset: offset}
+		if records == nil {
+			return emit(line)
+		}
+		if record, ok := records.add(line); ok {
+			return emit(record)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if records != nil && !stopped {
+		if record, ok := records.flush(); ok {
+			emit(record)
+		}
+	}
+	return ctx.Err()
+}
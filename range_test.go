@@ -0,0 +1,217 @@
+package cproject_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+// readLines reads the lines streamed on the line channel and the error sent on the error channel.
+func readLines(lines chan cproject.Line, errChan chan error) ([]string, error) {
+	got := []string{}
+	for line := range lines {
+		text := line.Text
+		if line.Source != "" {
+			text = filepath.Base(line.Source) + ":" + text
+		}
+		got = append(got, text)
+	}
+	return got, <-errChan
+}
+
+func TestLogFileHeadLines(t *testing.T) {
+	errorLines := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+
+	testCases := []struct {
+		desc    string
+		lines   int
+		order   cproject.Order
+		filters []cproject.Filter
+		want    []string
+	}{
+		{
+			desc:  "firstTwo",
+			lines: 2,
+			want:  fxtContextLines[:2],
+		}, {
+			desc:  "orderIgnored",
+			lines: 2,
+			order: cproject.OrderForward,
+			want:  fxtContextLines[:2],
+		}, {
+			desc:    "filtered",
+			lines:   1,
+			filters: []cproject.Filter{errorLines},
+			want:    fxtContextLines[2:3],
+		}, {
+			desc:  "all",
+			lines: 0,
+			want:  fxtContextLines,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				got, err := readLines(logFile.HeadLines(context.Background(), tC.lines, tC.filters...))
+				if err != nil {
+					t.Error(err)
+				}
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLogFileHeadLinesRecords(t *testing.T) {
+	timestampStart, err := cproject.NewMatchRegexp(`^\d{4}-\d{2}-\d{2}T`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, compressed := FxtRecordsFile(t)
+
+	for _, path := range []string{plain, compressed} {
+		logFile, err := cproject.OpenLogFile(path, cproject.WithRecordStart(timestampStart))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logFile.Close()
+
+		got, err := readLines(logFile.HeadLines(context.Background(), 3))
+		if err != nil {
+			t.Error(err)
+		}
+		if want := fxtRecords[:3]; !cproject.StringSlicesEqual(want, got) {
+			t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), want, got)
+		}
+	}
+}
+
+func TestLogFileRangeLines(t *testing.T) {
+	// offsets are the offsets of the lines of the context fixture.
+	offsets := []int64{}
+	offset := int64(0)
+	for _, line := range fxtContextLines {
+		offsets = append(offsets, offset)
+		offset += int64(len(line)) + 1
+	}
+	errorLines := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"ERROR"}))
+
+	testCases := []struct {
+		desc    string
+		rng     cproject.Range
+		lines   int
+		filters []cproject.Filter
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "bytes",
+			rng:  cproject.Range{Start: offsets[2], End: offsets[4]},
+			want: fxtContextLines[2:4],
+		}, {
+			desc: "bytesMidLine",
+			rng:  cproject.Range{Start: offsets[2] + 1, End: offsets[4] + 1},
+			want: fxtContextLines[3:5],
+		}, {
+			desc: "bytesToEnd",
+			rng:  cproject.Range{Start: offsets[6]},
+			want: fxtContextLines[6:],
+		}, {
+			desc: "lines",
+			rng:  cproject.Range{Unit: cproject.RangeLines, Start: 3, End: 5},
+			want: fxtContextLines[2:4],
+		}, {
+			desc: "linesPastEnd",
+			rng:  cproject.Range{Unit: cproject.RangeLines, Start: 20},
+			want: []string{},
+		}, {
+			desc:    "filteredAndLimited",
+			rng:     cproject.Range{Unit: cproject.RangeLines, Start: 2},
+			lines:   1,
+			filters: []cproject.Filter{errorLines},
+			want:    fxtContextLines[2:3],
+		}, {
+			desc:    "invalid",
+			rng:     cproject.Range{Unit: cproject.RangeLines, Start: 0},
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtContextFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				got, err := readLines(logFile.RangeLines(context.Background(), tC.rng, tC.lines, tC.filters...))
+				if tC.wantErr != (err != nil) {
+					t.Errorf("unexpected error for %s - want error: %t, got: %v", filepath.Base(path), tC.wantErr, err)
+				}
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMergedLogFileHeadLines(t *testing.T) {
+	logFile, err := cproject.NewMergedLogFile(FxtMergeSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	got, err := readLines(logFile.HeadLines(context.Background(), 5))
+	if err != nil {
+		t.Error(err)
+	}
+	want := []string{
+		"nginx.log:" + `10.0.0.1 - - [20/Feb/2024:07:10:40 +0000] "GET /checkout HTTP/1.1" 200 12`,
+		"app.log:2024-02-20T07:10:41Z checkout started",
+		"worker.log:2024-02-20T07:10:42Z charge declined",
+		"app.log:2024-02-20T07:10:43Z checkout failed",
+		"app.log:  at Checkout.pay()",
+	}
+	if !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+
+	if _, err := readLines(logFile.RangeLines(context.Background(), cproject.Range{}, 0)); err == nil {
+		t.Errorf("no error returned - expected error for a range of merged log files")
+	}
+}
+
+func TestRotatedLogFileHeadLines(t *testing.T) {
+	logFile, err := cproject.NewRotatedLogFile(FxtRotatedSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	got, err := readLines(logFile.HeadLines(context.Background(), 4))
+	if err != nil {
+		t.Error(err)
+	}
+	want := []string{"app.log.10:line 0 match", "app.log.2.gz:line 1 match", "app.log.2.gz:line 2", "app.log.1:line 5"}
+	if !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected results - want: %#v, got: %#v", want, got)
+	}
+}
@@ -0,0 +1,142 @@
+package cproject
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Unindented is a filter that includes lines that don't start with whitespace. As the start of records (see
+// WithRecordStart) it makes indented lines, such as the frames of a stack trace, part of the line before them.
+type Unindented struct{}
+
+// Include determines if a line of text should be included in the result set.
+func (Unindented) Include(s string) bool {
+	return s != "" && s[0] != ' ' && s[0] != '\t'
+}
+
+// joinRecord joins the lines of a record, in the order they appear in the log file, into one line. The record starts
+// at the offset of its first line.
+func joinRecord(lines []Line) Line {
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+	}
+	record := lines[0]
+	record.Text = strings.Join(texts, string(newline))
+	return record
+}
+
+// reverseRecords assembles records from lines read newest first. A record is a line included by the `start` filter
+// followed by the lines that aren't.
+type reverseRecords struct {
+	start Filter
+	// continuation are the lines read since the last record, newest first.
+	continuation []Line
+}
+
+// add adds a line to the record being assembled. If the line starts a record, the record is complete and returned.
+func (r *reverseRecords) add(line Line) (Line, bool) {
+	if !r.start.Include(line.Text) {
+		r.continuation = append(r.continuation, line)
+		return Line{}, false
+	}
+
+	lines := make([]Line, 0, len(r.continuation)+1)
+	lines = append(lines, line)
+	for i := len(r.continuation) - 1; i >= 0; i-- {
+		lines = append(lines, r.continuation[i])
+	}
+	r.continuation = r.continuation[:0]
+	return joinRecord(lines), true
+}
+
+// flush returns the lines left over at the beginning of the file, which don't have a line starting their record, as
+// a record of their own.
+func (r *reverseRecords) flush() (Line, bool) {
+	if len(r.continuation) == 0 {
+		return Line{}, false
+	}
+	lines := make([]Line, 0, len(r.continuation))
+	for i := len(r.continuation) - 1; i >= 0; i-- {
+		lines = append(lines, r.continuation[i])
+	}
+	r.continuation = r.continuation[:0]
+	return joinRecord(lines), true
+}
+
+// forwardRecords assembles records from lines read in the order they appear in the file. A record is a line included
+// by the `start` filter followed by the lines that aren't.
+type forwardRecords struct {
+	start Filter
+	// lines are the lines of the record being assembled.
+	lines []Line
+}
+
+// add adds a line to the record being assembled. If the line starts a record, the record before it is complete and
+// returned.
+func (r *forwardRecords) add(line Line) (Line, bool) {
+	var record Line
+	complete := false
+	if r.start.Include(line.Text) && len(r.lines) > 0 {
+		record, complete = r.flush()
+	}
+	r.lines = append(r.lines, line)
+	return record, complete
+}
+
+// flush returns the record being assembled, if there is one.
+func (r *forwardRecords) flush() (Line, bool) {
+	if len(r.lines) == 0 {
+		return Line{}, false
+	}
+	record := joinRecord(r.lines)
+	r.lines = r.lines[:0]
+	return record, true
+}
+
+// tailRecords reads up to `numLines` records from the end of the part of the provided file described by `within` and
+// passes them to `fn` in the requested order. A record is a line included by the `start` filter followed by the
+// lines that aren't, joined with newlines; the filters are applied to whole records. Lines are read in reverse, so
+// records in forward order are gathered before they are passed along. Reading stops when `fn` returns false. If the
+// context is done before reading finishes, the context's error is returned.
+func tailRecords(ctx context.Context, file *os.File, within lineSpan, numLines int, filters []Filter, order Order,
+	start Filter, fn func(Line) bool) error {
+	records := &reverseRecords{start: start}
+	gathered := []Line{}
+	count := 0
+	emit := func(record Line) bool {
+		if !passesFilters(record.Text, filters) {
+			return true
+		}
+		count++
+		if order == OrderForward {
+			gathered = append(gathered, record)
+		} else if !fn(record) {
+			return false
+		}
+		return numLines <= 0 || count < numLines
+	}
+
+	stopped := false
+	err := tailLines(ctx, file, within, 0, nil, OrderReverse, func(line Line) bool {
+		if record, ok := records.add(line); ok && !emit(record) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if record, ok := records.flush(); ok && !stopped {
+		emit(record)
+	}
+
+	for i := len(gathered) - 1; i >= 0; i-- {
+		if !fn(gathered[i]) {
+			break
+		}
+	}
+	return ctx.Err()
+}
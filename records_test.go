@@ -0,0 +1,113 @@
+package cproject_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+// fxtRecords are the records of the records fixture; the first is left over from a record before the file starts.
+var fxtRecords = []string{
+	"\tat zoo.Keeper.feed(Keeper.java:42)",
+	"2024-02-20T07:10:40Z INFO fed the monkey",
+	strings.Join([]string{
+		"2024-02-20T07:10:41Z ERROR feeding failed",
+		"java.lang.IllegalStateException: out of bananas",
+		"\tat zoo.Pantry.take(Pantry.java:7)",
+		"\tat zoo.Keeper.feed(Keeper.java:42)",
+	}, "\n"),
+	"2024-02-20T07:10:42Z INFO fed the octopus",
+	strings.Join([]string{
+		"2024-02-20T07:10:43Z ERROR feeding failed",
+		"Traceback (most recent call last):",
+		"  File \"zoo.py\", line 7, in take",
+		"KeyError: 'crabs'",
+	}, "\n"),
+}
+
+// FxtRecordsFile creates a log file of the records fixture, along with a gzip compressed copy, and returns their
+// paths.
+func FxtRecordsFile(t *testing.T) (string, string) {
+	t.Helper()
+	return cproject.FxtPlainAndGzip(t, "app.log", strings.Join(fxtRecords, "\n")+"\n")
+}
+
+func TestLogFileYieldLinesRecords(t *testing.T) {
+	timestampStart, err := cproject.NewMatchRegexp(`^\d{4}-\d{2}-\d{2}T`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exception := cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"Exception"}))
+
+	testCases := []struct {
+		desc    string
+		start   cproject.Filter
+		lines   int
+		order   cproject.Order
+		filters []cproject.Filter
+		want    []string
+	}{
+		{
+			desc:  "lastTwo",
+			start: timestampStart,
+			lines: 2,
+			want:  []string{fxtRecords[4], fxtRecords[3]},
+		}, {
+			desc:  "lastTwoForward",
+			start: timestampStart,
+			lines: 2,
+			order: cproject.OrderForward,
+			want:  []string{fxtRecords[3], fxtRecords[4]},
+		}, {
+			desc:    "filtered",
+			start:   timestampStart,
+			lines:   10,
+			filters: []cproject.Filter{exception},
+			want:    []string{fxtRecords[2]},
+		}, {
+			desc:  "all",
+			start: timestampStart,
+			lines: 0,
+			want:  []string{fxtRecords[4], fxtRecords[3], fxtRecords[2], fxtRecords[1], fxtRecords[0]},
+		}, {
+			desc:  "unindented",
+			start: cproject.Unindented{},
+			lines: 3,
+			want: []string{
+				"KeyError: 'crabs'",
+				"Traceback (most recent call last):\n  File \"zoo.py\", line 7, in take",
+				"2024-02-20T07:10:43Z ERROR feeding failed",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtRecordsFile(t)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order),
+					cproject.WithRecordStart(tC.start))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				lines, errChan := logFile.YieldLines(tC.lines, tC.filters...)
+
+				var got []string
+				for line := range lines {
+					got = append(got, line)
+				}
+				if err := <-errChan; err != nil {
+					t.Error(err)
+				}
+
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package cproject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rotatedSuffix matches the suffix logrotate adds to a rotated log file (e.g. `.1`, `.2.gz`, `.3.bz2`).
+var rotatedSuffix = regexp.MustCompile(`^\.(\d+)(\.gz|\.bz2)?$`)
+
+// rotatedPaths returns the paths of the files that make up the rotated log file set of `path`, newest to oldest:
+// `path` itself (if it exists) followed by `path.1`, `path.2.gz`, etc.
+func rotatedPaths(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	type rotated struct {
+		path  string
+		index int
+	}
+	set := []rotated{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		m := rotatedSuffix.FindStringSubmatch(name[len(base):])
+		if m == nil {
+			continue
+		}
+		index, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		set = append(set, rotated{path: path + name[len(base):], index: index})
+	}
+	sort.SliceStable(set, func(i, j int) bool { return set[i].index < set[j].index })
+
+	paths := []string{}
+	if _, err := os.Stat(path); err == nil {
+		paths = append(paths, path)
+	}
+	for _, r := range set {
+		paths = append(paths, r.path)
+	}
+	return paths, nil
+}
+
+// RotatedLogFile reads a rotated log file set as one logical log file. Given the path of a log file (e.g.
+// `/var/log/app.log`), the current file and its rotated files (`app.log.1`, `app.log.2.gz`, ...) are read newest to
+// oldest until the requested number of lines are found (or oldest to newest for the first lines). Compressed rotated
+// files are read with CompressedLogFile.
+type RotatedLogFile struct {
+	path  string
+	paths []string
+	opts  []logFileOpt
+	order Order
+}
+
+// NewRotatedLogFile creates a new RotatedLogFile for the log file at `path` and applies the provided options to
+// each of the files in the set. An error is returned if no files in the set exist.
+func NewRotatedLogFile(path string, opts ...logFileOpt) (*RotatedLogFile, error) {
+	paths, err := rotatedPaths(path)
+	if err != nil {
+		return nil, fmt.Errorf("error finding rotated files: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("error opening file: %w", &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist})
+	}
+
+	// the order applies to the set as a whole, so pull it out of the options.
+	cfg := &LogFile{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &RotatedLogFile{
+		path:  path,
+		paths: paths,
+		opts:  opts,
+		order: cfg.order,
+	}, nil
+}
+
+// Path is the path to the log file being read.
+func (f *RotatedLogFile) Path() string {
+	return f.path
+}
+
+// Paths are the paths of the files in the set, newest to oldest.
+func (f *RotatedLogFile) Paths() []string {
+	return f.paths
+}
+
+// readFile reads the lines streamed by `yield` from one of the files in the set and passes them to `fn`. It returns
+// the number of lines passed to `fn` and false if `fn` asked to stop.
+func (f *RotatedLogFile) readFile(ctx context.Context, path string,
+	yield func(context.Context, LogFileReader) (chan Line, chan error), fn func(Line) bool) (int, bool, error) {
+	// context lines aren't read from more than one log file and the end of one file isn't the end of the others.
+	opts := append(append([]logFileOpt{}, f.opts...), WithOrder(OrderReverse), WithContext(0, 0), WithEnd(endOfFile))
+	logFile, err := OpenLogFile(path, opts...)
+	if err != nil {
+		// the set was rotated while we were reading it.
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, true, nil
+		}
+		return 0, true, err
+	}
+	defer logFile.Close()
+
+	// stop reading the file as soon as we stop consuming it.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := 0
+	lines, errChan := yield(ctx, logFile)
+	for line := range lines {
+		count++
+		line.Source = path
+		if !fn(line) {
+			cancel()
+			for range lines {
+			}
+			return count, false, nil
+		}
+	}
+	return count, true, <-errChan
+}
+
+// tailLines reads up to `numLines` lines from the end of the set and passes them to `fn` in the configured order.
+// Files are read newest to oldest until enough lines are found. Reading stops when `fn` returns false.
+func (f *RotatedLogFile) tailLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	// lines in forward order have to be gathered (newest first) before they can be passed along oldest first.
+	gathered := []Line{}
+	collect := fn
+	if f.order == OrderForward {
+		collect = func(line Line) bool {
+			gathered = append(gathered, line)
+			return true
+		}
+	}
+
+	remaining := numLines
+	for _, path := range f.paths {
+		count, ok, err := f.readFile(ctx, path, func(ctx context.Context, logFile LogFileReader) (chan Line,
+			chan error) {
+			return logFile.YieldLinesContext(ctx, remaining, filters...)
+		}, collect)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ctx.Err()
+		}
+		if numLines > 0 {
+			remaining -= count
+			if remaining <= 0 {
+				break
+			}
+		}
+	}
+
+	for i := len(gathered) - 1; i >= 0; i-- {
+		if !fn(gathered[i]) {
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+// headLines reads up to `numLines` lines from the start of the set and passes them to `fn` in the order they appear.
+// Files are read oldest to newest until enough lines are found. Reading stops when `fn` returns false.
+func (f *RotatedLogFile) headLines(ctx context.Context, numLines int, filters []Filter, fn func(Line) bool) error {
+	remaining := numLines
+	for i := len(f.paths) - 1; i >= 0; i-- {
+		count, ok, err := f.readFile(ctx, f.paths[i], func(ctx context.Context, logFile LogFileReader) (chan Line,
+			chan error) {
+			return logFile.HeadLines(ctx, remaining, filters...)
+		}, fn)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ctx.Err()
+		}
+		if numLines > 0 {
+			remaining -= count
+			if remaining <= 0 {
+				break
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// YieldLines returns a string channel and an error channel for streaming lines from a log file.
+func (f *RotatedLogFile) YieldLines(numLines int, filters ...Filter) (chan string, chan error) {
+	return streamText(func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// YieldLinesContext returns a line channel and an error channel for streaming lines from a log file. Streaming stops,
+// and the context's error is sent on the error channel, when the context is done.
+func (f *RotatedLogFile) YieldLinesContext(ctx context.Context, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.tailLines(ctx, numLines, filters, fn)
+	})
+}
+
+// HeadLines returns a line channel and an error channel for streaming the first `numLines` lines of the set, in the
+// order they appear. Streaming stops, and the context's error is sent on the error channel, when the context is done.
+func (f *RotatedLogFile) HeadLines(ctx context.Context, numLines int, filters ...Filter) (chan Line, chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return f.headLines(ctx, numLines, filters, fn)
+	})
+}
+
+// RangeLines sends an error on the error channel; ranges can't be read from more than one log file.
+func (f *RotatedLogFile) RangeLines(ctx context.Context, rng Range, numLines int, filters ...Filter) (chan Line,
+	chan error) {
+	return streamLines(ctx, func(ctx context.Context, fn func(Line) bool) error {
+		return errRangeMultipleFiles
+	})
+}
+
+// Close releases the log file set. Files in the set are only open while they are being read, so there's nothing to
+// close.
+func (f *RotatedLogFile) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package cproject_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+// FxtRotatedSet creates a rotated log file set in a temporary directory and returns the path of the current file.
+func FxtRotatedSet(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	files := map[string][]byte{
+		"app.log":      []byte("line 7 match\nline 8\n"),
+		"app.log.1":    []byte("line 5\nline 6 match\n"),
+		"app.log.2.gz": cproject.FxtGzip(t, "line 1 match\nline 2\n"),
+		"app.log.10":   []byte("line 0 match\n"),
+		"app.log.bak":  []byte("not part of the set\n"),
+		"app.logger":   []byte("not part of the set\n"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestRotatedLogFilePaths(t *testing.T) {
+	path := FxtRotatedSet(t)
+
+	logFile, err := cproject.NewRotatedLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{path, path + ".1", path + ".2.gz", path + ".10"}
+	if got := logFile.Paths(); !cproject.StringSlicesEqual(want, got) {
+		t.Errorf("unexpected paths - want: %#v, got: %#v", want, got)
+	}
+
+	if _, err := cproject.NewRotatedLogFile(filepath.Join(filepath.Dir(path), "missing.log")); err == nil {
+		t.Errorf("no error returned - expected error for a missing set")
+	}
+}
+
+func TestRotatedLogFileYieldLines(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		lines   int
+		order   cproject.Order
+		filters []cproject.Filter
+		want    []string
+	}{
+		{
+			desc:  "currentFileOnly",
+			lines: 2,
+			want:  []string{"line 8", "line 7 match"},
+		}, {
+			desc:  "acrossFiles",
+			lines: 5,
+			want:  []string{"line 8", "line 7 match", "line 6 match", "line 5", "line 2"},
+		}, {
+			desc:  "acrossFilesForward",
+			lines: 5,
+			order: cproject.OrderForward,
+			want:  []string{"line 2", "line 5", "line 6 match", "line 7 match", "line 8"},
+		}, {
+			desc:  "filteredRareMatches",
+			lines: 3,
+			filters: []cproject.Filter{
+				cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"match"})),
+			},
+			want: []string{"line 7 match", "line 6 match", "line 1 match"},
+		}, {
+			desc:  "all",
+			lines: 0,
+			filters: []cproject.Filter{
+				cproject.NewMatchAnySubstring(cproject.WithSubstrings([]string{"match"})),
+			},
+			want: []string{"line 7 match", "line 6 match", "line 1 match", "line 0 match"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := FxtRotatedSet(t)
+
+			logFile, err := cproject.NewRotatedLogFile(path, cproject.WithOrder(tC.order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logFile.Close()
+
+			lines, errChan := logFile.YieldLines(tC.lines, tC.filters...)
+
+			var got []string
+			for line := range lines {
+				got = append(got, line)
+			}
+			if err := <-errChan; err != nil {
+				t.Error(err)
+			}
+
+			if !cproject.StringSlicesEqual(tC.want, got) {
+				t.Errorf("unexpected results - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package cproject
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// rfc3164Line matches a BSD syslog line: an optional PRI, the timestamp, the host, an optional app (with an
+	// optional pid) and the message.
+	rfc3164Line = regexp.MustCompile(
+		`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) (?:([^\s:\[]+)(?:\[([^\]]*)\])?: ?)?(.*)$`)
+	// rfc5424Line matches the header of an RFC 5424 syslog line; the structured data and message follow it.
+	rfc5424Line = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) `)
+
+	// syslogFacilities are the names of the syslog facilities by code.
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp", "ntp",
+		"security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5", "local6",
+		"local7",
+	}
+	// syslogSeverities are the names of the syslog severities by code.
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// syslogNil is the value of an RFC 5424 header field that has no value.
+const syslogNil = "-"
+
+// parsePRI parses a syslog PRI into the priority, facility and severity fields.
+func parsePRI(pri string, fields Fields) bool {
+	priority, err := strconv.Atoi(pri)
+	if err != nil || priority > 191 {
+		return false
+	}
+	fields["priority"] = priority
+	fields["facility"] = syslogFacilities[priority/8]
+	fields["severity"] = syslogSeverities[priority%8]
+	return true
+}
+
+// SyslogParser parses BSD syslog lines (RFC 3164) such as those written to `/var/log/syslog`:
+// `Feb 20 07:10:42 zoo monkey[42]: fed 2 bananas`. The fields are `timestamp` (RFC3339, local time in the past
+// year), `host`, `app`, `pid` and `message`, plus `priority`, `facility` and `severity` when the line starts with a PRI
+// (`<34>`).
+type SyslogParser struct{}
+
+// Parse parses the fields of a line. If the line isn't BSD syslog, false is returned.
+func (SyslogParser) Parse(line string) (Fields, bool) {
+	m := rfc3164Line.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	ts, err := time.ParseInLocation(syslogLayout, m[2], time.Local)
+	if err != nil {
+		return nil, false
+	}
+
+	fields := Fields{
+		"timestamp": inPastYear(ts).Format(time.RFC3339),
+		"host":      m[3],
+		"message":   m[6],
+	}
+	if m[1] != "" && !parsePRI(m[1], fields) {
+		return nil, false
+	}
+	if m[4] != "" {
+		fields["app"] = m[4]
+	}
+	if m[5] != "" {
+		fields["pid"] = m[5]
+	}
+	return fields, true
+}
+
+// RFC5424Parser parses syslog lines in the RFC 5424 format:
+// `<165>1 2024-02-20T07:10:42.003Z zoo monkey 42 ID47 [feed@32473 food="banana"] fed 2 bananas`. The fields are
+// `priority`, `facility`, `severity`, `version`, `timestamp`, `host`, `app`, `pid`, `msgid`, `structured_data` and
+// `message`; header fields without a value (`-`) are left out. Structured data is nested by SD-ID, so the example's
+// food is `structured_data.feed@32473.food`.
+type RFC5424Parser struct{}
+
+// Parse parses the fields of a line. If the line isn't RFC 5424 syslog, false is returned.
+func (RFC5424Parser) Parse(line string) (Fields, bool) {
+	m := rfc5424Line.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	fields := Fields{"version": m[2]}
+	if !parsePRI(m[1], fields) {
+		return nil, false
+	}
+	for i, name := range []string{"timestamp", "host", "app", "pid", "msgid"} {
+		if value := m[i+3]; value != syslogNil {
+			fields[name] = value
+		}
+	}
+
+	rest := line[len(m[0]):]
+	sd, n, ok := parseStructuredData(rest)
+	if !ok {
+		return nil, false
+	}
+	if len(sd) > 0 {
+		fields["structured_data"] = sd
+	}
+	rest = rest[n:]
+	if rest != "" {
+		if rest[0] != ' ' {
+			return nil, false
+		}
+		fields["message"] = strings.TrimPrefix(rest[1:], "\ufeff")
+	}
+	return fields, true
+}
+
+// parseStructuredData parses the RFC 5424 structured data at the start of `s` into params keyed by SD-ID. It returns
+// the structured data and the number of bytes it took up. If the structured data is malformed, false is returned.
+func parseStructuredData(s string) (map[string]interface{}, int, bool) {
+	sd := map[string]interface{}{}
+	if strings.HasPrefix(s, syslogNil) {
+		return sd, len(syslogNil), true
+	}
+
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		end := strings.IndexAny(s[i:], " ]")
+		if end < 0 {
+			return nil, 0, false
+		}
+		id := s[i+1 : i+end]
+		params := map[string]interface{}{}
+		sd[id] = params
+		i += end
+
+		for i < len(s) && s[i] == ' ' {
+			eq := strings.Index(s[i:], `="`)
+			if eq < 0 {
+				return nil, 0, false
+			}
+			name := s[i+1 : i+eq]
+			i += eq + 2
+
+			var value strings.Builder
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, 0, false
+			}
+			params[name] = value.String()
+			i++
+		}
+
+		if i == len(s) || s[i] != ']' {
+			return nil, 0, false
+		}
+		i++
+	}
+	if i == 0 {
+		return nil, 0, false
+	}
+	return sd, i, true
+}
@@ -0,0 +1,113 @@
+package cproject_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marklap/cproject"
+)
+
+func TestSyslogParser(t *testing.T) {
+	testCases := []struct {
+		desc string
+		line string
+		want cproject.Fields
+	}{
+		{
+			desc: "appAndPid",
+			line: "Feb 20 07:10:42 zoo monkey[42]: fed 2 bananas",
+			want: cproject.Fields{"host": "zoo", "app": "monkey", "pid": "42", "message": "fed 2 bananas"},
+		}, {
+			desc: "pri",
+			line: "<34>Feb  3 07:10:42 zoo su: 'su root' failed for marklap on /dev/pts/8",
+			want: cproject.Fields{
+				"priority": 34, "facility": "auth", "severity": "crit", "host": "zoo", "app": "su",
+				"message": "'su root' failed for marklap on /dev/pts/8",
+			},
+		}, {
+			desc: "noApp",
+			line: "Feb 20 07:10:42 zoo last message repeated 3 times",
+			want: cproject.Fields{"host": "zoo", "message": "last message repeated 3 times"},
+		}, {
+			desc: "notSyslog",
+			line: "2024-02-20T07:10:42Z zoo monkey[42]: fed 2 bananas",
+		}, {
+			desc: "badPri",
+			line: "<192>Feb 20 07:10:42 zoo monkey[42]: fed 2 bananas",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := cproject.SyslogParser{}.Parse(tC.line)
+			if ok != (tC.want != nil) {
+				t.Fatalf("unexpected parse result - want: %t, got: %t (%#v)", tC.want != nil, ok, got)
+			}
+			if !ok {
+				return
+			}
+			// the year and zone of a syslog timestamp depend on when and where the test runs.
+			if ts, _ := got["timestamp"].(string); !strings.Contains(ts, ":10:42") {
+				t.Errorf("unexpected timestamp: %q", ts)
+			}
+			delete(got, "timestamp")
+			if !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("unexpected fields - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestRFC5424Parser(t *testing.T) {
+	testCases := []struct {
+		desc string
+		line string
+		want cproject.Fields
+	}{
+		{
+			desc: "structuredData",
+			line: `<165>1 2024-02-20T07:10:42.003Z zoo monkey 42 ID47 ` +
+				`[feed@32473 food="banana" note="a \"ripe\" one"][meta seq="3"] fed 2 bananas`,
+			want: cproject.Fields{
+				"priority": 165, "facility": "local4", "severity": "notice", "version": "1",
+				"timestamp": "2024-02-20T07:10:42.003Z", "host": "zoo", "app": "monkey", "pid": "42", "msgid": "ID47",
+				"structured_data": map[string]interface{}{
+					"feed@32473": map[string]interface{}{"food": "banana", "note": `a "ripe" one`},
+					"meta":       map[string]interface{}{"seq": "3"},
+				},
+				"message": "fed 2 bananas",
+			},
+		}, {
+			desc: "nilValues",
+			line: "<13>1 2024-02-20T07:10:42Z zoo - - - - \ufefffed",
+			want: cproject.Fields{
+				"priority": 13, "facility": "user", "severity": "notice", "version": "1",
+				"timestamp": "2024-02-20T07:10:42Z", "host": "zoo", "message": "fed",
+			},
+		}, {
+			desc: "noMessage",
+			line: "<13>1 - zoo monkey - - -",
+			want: cproject.Fields{
+				"priority": 13, "facility": "user", "severity": "notice", "version": "1", "host": "zoo",
+				"app": "monkey",
+			},
+		}, {
+			desc: "unterminatedStructuredData",
+			line: `<13>1 - zoo monkey - - [feed food="banana"`,
+		}, {
+			desc: "bsdSyslog",
+			line: "<34>Feb 20 07:10:42 zoo su: 'su root' failed",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := cproject.RFC5424Parser{}.Parse(tC.line)
+			if ok != (tC.want != nil) {
+				t.Fatalf("unexpected parse result - want: %t, got: %t (%#v)", tC.want != nil, ok, got)
+			}
+			if ok && !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("unexpected fields - want: %#v, got: %#v", tC.want, got)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@
 package cproject
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -47,6 +50,34 @@ func FxtFile(t *testing.T, content string) (*os.File, error) {
 	return fh, nil
 }
 
+// Compresses `content` with gzip.
+func FxtGzip(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Creates a file named `name` with the contents of `content` in a temporary directory, along with a gzip compressed
+// copy of it (`name` with a `.gz` extension), and returns the paths of both.
+func FxtPlainAndGzip(t *testing.T, name, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".gz", FxtGzip(t, content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, path + ".gz"
+}
+
 // Create a new `LogFile` with the given path and file handle.
 func FxtLogFile(path string, file *os.File) (*LogFile, error) {
 	return NewLogFile(path, WithFile(file))
@@ -0,0 +1,101 @@
+package cproject
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// linearSearchSize is the size of the part of a file left to search for a timestamp below which it's quicker to
+	// read the lines one after another than to keep halving it.
+	linearSearchSize int64 = 4 * stdBufSize
+)
+
+// inTimeRange returns true if the timestamp is within the range `since` to `until` (inclusive). A zero `since` or
+// `until` leaves that end of the range open.
+func inTimeRange(ts, since, until time.Time) bool {
+	return (since.IsZero() || !ts.Before(since)) && (until.IsZero() || !ts.After(until))
+}
+
+// nextTimestamp finds the first line with a timestamp that starts after the byte offset `from` (the line `from` is
+// in is skipped because it may have been cut off) and before `end`. It returns the offset of that line, the offset of
+// the line after it and its timestamp. If there's no such line, found is false.
+func nextTimestamp(file *os.File, from, end int64) (offset, next int64, ts time.Time, found bool, err error) {
+	skipped := false
+	err = scanForward(file, lineSpan{start: from, end: end}, func(line string, lineOffset int64) bool {
+		if !skipped {
+			skipped = true
+			return true
+		}
+		if parsed, ok := ParseTimestamp(line); ok {
+			offset, next, ts, found = lineOffset, lineOffset+int64(len(line))+1, parsed, true
+			return false
+		}
+		return true
+	})
+	return offset, next, ts, found, err
+}
+
+// searchTime returns the offset of the first line in the part of the file described by `within` with a timestamp
+// that satisfies `match`. Lines are assumed to be in timestamp order, so once a line's timestamp satisfies `match`
+// all of the lines after it do too; that lets the file be binary searched by byte offset, sniffing the timestamp of
+// the line after each midpoint, rather than read line by line. Lines without a timestamp are skipped. If no line
+// matches, the end of `within` is returned.
+func searchTime(file *os.File, within lineSpan, match func(time.Time) bool) (int64, error) {
+	// the first matching line starts somewhere from lo up to hi.
+	lo, hi := within.start, within.end
+	for hi-lo > linearSearchSize {
+		mid := lo + (hi-lo)/2
+		offset, next, ts, found, err := nextTimestamp(file, mid, hi)
+		if err != nil {
+			return within.end, err
+		}
+		if !found {
+			// nothing to go on in the top half; fall back to reading what's left line by line.
+			break
+		}
+		if match(ts) {
+			hi = offset
+		} else {
+			lo = next
+		}
+	}
+
+	result := hi
+	err := scanForward(file, lineSpan{start: lo, end: hi}, func(line string, offset int64) bool {
+		if ts, ok := ParseTimestamp(line); ok && match(ts) {
+			result = offset
+			return false
+		}
+		return true
+	})
+	return result, err
+}
+
+// timeSpan returns the span of the file holding the lines with timestamps from `since` to `until` (inclusive). A
+// zero `since` or `until` leaves that end of the range open. Lines without a timestamp go with the line before them.
+func timeSpan(file *os.File, since, until time.Time) (lineSpan, error) {
+	if since.IsZero() && until.IsZero() {
+		return wholeFile, nil
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return wholeFile, err
+	}
+	span := lineSpan{start: 0, end: stat.Size()}
+
+	if !since.IsZero() {
+		span.start, err = searchTime(file, span, func(ts time.Time) bool { return !ts.Before(since) })
+		if err != nil {
+			return span, err
+		}
+	}
+	if !until.IsZero() {
+		span.end, err = searchTime(file, span, func(ts time.Time) bool { return ts.After(until) })
+		if err != nil {
+			return span, err
+		}
+	}
+	return span, nil
+}
@@ -0,0 +1,134 @@
+package cproject_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marklap/cproject"
+)
+
+// fxtTimeRangeStart is the timestamp of the first line of the time range fixture.
+var fxtTimeRangeStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fxtTimeRangeLine is line `n` of the time range fixture; line `n` is logged `n` seconds after the fixture starts.
+func fxtTimeRangeLine(n int) string {
+	ts := fxtTimeRangeStart.Add(time.Duration(n) * time.Second)
+	return fmt.Sprintf("%s INFO request %04d", ts.Format(time.RFC3339), n)
+}
+
+// fxtTimeRangeTrace is the line without a timestamp that follows every tenth line of the time range fixture.
+func fxtTimeRangeTrace(n int) string {
+	return fmt.Sprintf("    at handler (request %04d)", n)
+}
+
+// FxtTimeRangeFile creates a log file with a line a second for `n` seconds, big enough that finding a time range
+// takes more than one read, along with a gzip compressed copy. The paths of both are returned.
+func FxtTimeRangeFile(t *testing.T, n int) (string, string) {
+	t.Helper()
+	var content strings.Builder
+	for i := 0; i < n; i++ {
+		content.WriteString(fxtTimeRangeLine(i) + "\n")
+		if i%10 == 0 {
+			content.WriteString(fxtTimeRangeTrace(i) + "\n")
+		}
+	}
+	return cproject.FxtPlainAndGzip(t, "app.log", content.String())
+}
+
+func TestLogFileYieldLinesTimeRange(t *testing.T) {
+	at := func(n int) time.Time {
+		return fxtTimeRangeStart.Add(time.Duration(n) * time.Second)
+	}
+
+	testCases := []struct {
+		desc  string
+		since time.Time
+		until time.Time
+		lines int
+		order cproject.Order
+		want  []string
+	}{
+		{
+			desc:  "window",
+			since: at(599),
+			until: at(601),
+			want: []string{
+				fxtTimeRangeLine(601), fxtTimeRangeTrace(600), fxtTimeRangeLine(600), fxtTimeRangeLine(599),
+			},
+		}, {
+			desc:  "windowForward",
+			since: at(599),
+			until: at(601),
+			order: cproject.OrderForward,
+			want: []string{
+				fxtTimeRangeLine(599), fxtTimeRangeLine(600), fxtTimeRangeTrace(600), fxtTimeRangeLine(601),
+			},
+		}, {
+			desc:  "windowBetweenTimestamps",
+			since: at(599).Add(time.Millisecond),
+			until: at(601).Add(-time.Millisecond),
+			want:  []string{fxtTimeRangeTrace(600), fxtTimeRangeLine(600)},
+		}, {
+			desc:  "windowLimited",
+			since: at(100),
+			until: at(1200),
+			lines: 2,
+			want:  []string{fxtTimeRangeTrace(1200), fxtTimeRangeLine(1200)},
+		}, {
+			desc:  "windowLimitedForward",
+			since: at(100),
+			until: at(1200),
+			lines: 2,
+			order: cproject.OrderForward,
+			want:  []string{fxtTimeRangeLine(1200), fxtTimeRangeTrace(1200)},
+		}, {
+			desc:  "sinceOnly",
+			since: at(1000),
+			lines: 2,
+			want:  []string{fxtTimeRangeLine(1999), fxtTimeRangeLine(1998)},
+		}, {
+			desc:  "untilOnly",
+			until: at(10),
+			lines: 3,
+			want:  []string{fxtTimeRangeTrace(10), fxtTimeRangeLine(10), fxtTimeRangeLine(9)},
+		}, {
+			desc:  "beforeFirstLine",
+			since: at(-100),
+			until: at(-1),
+		}, {
+			desc:  "afterLastLine",
+			since: at(2000),
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			plain, compressed := FxtTimeRangeFile(t, 2000)
+
+			for _, path := range []string{plain, compressed} {
+				logFile, err := cproject.OpenLogFile(path, cproject.WithOrder(tC.order),
+					cproject.WithTimeRange(tC.since, tC.until))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer logFile.Close()
+
+				lines, errChan := logFile.YieldLines(tC.lines)
+
+				var got []string
+				for line := range lines {
+					got = append(got, line)
+				}
+				if err := <-errChan; err != nil {
+					t.Error(err)
+				}
+
+				if !cproject.StringSlicesEqual(tC.want, got) {
+					t.Errorf("unexpected results for %s - want: %#v, got: %#v", filepath.Base(path), tC.want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package cproject
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// timestampSearchLen is how far into a line a timestamp is looked for.
+	timestampSearchLen = 256
+)
+
+var (
+	// rfc3339Timestamp matches RFC3339 timestamps and the common variant with a space between the date and time.
+	rfc3339Timestamp = regexp.MustCompile(
+		`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	// syslogTimestamp matches the BSD syslog timestamp at the start of a line, optionally after the PRI.
+	syslogTimestamp = regexp.MustCompile(`^(<\d{1,3}>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`)
+	// clfTimestamp matches the Common Log Format timestamp used by web server access logs.
+	clfTimestamp = regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+
+	// timeNow returns the current time; syslog timestamps are placed in the year before it.
+	timeNow = time.Now
+)
+
+const (
+	syslogLayout = "Jan _2 15:04:05"
+	clfLayout    = "02/Jan/2006:15:04:05 -0700"
+	// zonelessLayout is the RFC3339 layout without the time zone.
+	zonelessLayout = "2006-01-02T15:04:05.999999999"
+)
+
+// ParseTimestamp finds and parses the timestamp of a log line. RFC3339 (including the common variant with a space
+// between the date and time), syslog (`Jan _2 15:04:05`) and Common Log Format (`02/Jan/2006:15:04:05 -0700`)
+// timestamps are recognized. When a line has more than one, the first is used. Timestamps without a time zone (syslog
+// timestamps among them) are taken to be in the local time zone, the one they're written in, and syslog timestamps,
+// which don't have a year, are taken to be from the past year.
+func ParseTimestamp(line string) (time.Time, bool) {
+	if len(line) > timestampSearchLen {
+		line = line[:timestampSearchLen]
+	}
+
+	if m := syslogTimestamp.FindStringSubmatch(line); m != nil {
+		if ts, err := time.ParseInLocation(syslogLayout, m[2], time.Local); err == nil {
+			return inPastYear(ts), true
+		}
+	}
+
+	rfcLoc := rfc3339Timestamp.FindStringIndex(line)
+	clfLoc := clfTimestamp.FindStringSubmatchIndex(line)
+	if clfLoc != nil && (rfcLoc == nil || clfLoc[0] < rfcLoc[0]) {
+		if ts, err := time.Parse(clfLayout, line[clfLoc[2]:clfLoc[3]]); err == nil {
+			return ts, true
+		}
+	}
+	if rfcLoc != nil {
+		return parseRFC3339(line[rfcLoc[0]:rfcLoc[1]])
+	}
+
+	return time.Time{}, false
+}
+
+// parseRFC3339 parses an RFC3339 timestamp that may have a space between the date and time, a time zone offset
+// without a colon, or no time zone at all (local time).
+func parseRFC3339(s string) (time.Time, bool) {
+	s = strings.Replace(s, " ", "T", 1)
+	// date and time are always 19 bytes; normalize what follows to the RFC3339 time zone format.
+	zone := strings.TrimLeft(s[19:], ".0123456789")
+	fraction := s[19 : len(s)-len(zone)]
+	if zone == "" {
+		ts, err := time.ParseInLocation(zonelessLayout, s[:19]+fraction, time.Local)
+		return ts, err == nil
+	}
+	if len(zone) == 5 {
+		zone = zone[:3] + ":" + zone[3:]
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, s[:19]+fraction+zone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// inPastYear places a timestamp without a year in the year leading up to now, keeping its time zone.
+func inPastYear(ts time.Time) time.Time {
+	now := timeNow()
+	ts = time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(),
+		ts.Location())
+	// allow a little slack for clocks that are ahead of ours.
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts
+}
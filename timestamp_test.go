@@ -0,0 +1,102 @@
+package cproject
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	timeNow = func() time.Time { return time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC) }
+	local := time.Local
+	t.Cleanup(func() { timeNow, time.Local = time.Now, local })
+	// timestamps without a time zone are local time.
+	eastOfUTC := time.FixedZone("UTC+2", 2*60*60)
+
+	testCases := []struct {
+		desc   string
+		line   string
+		local  *time.Location
+		want   time.Time
+		wantOk bool
+	}{
+		{
+			desc:   "rfc3339",
+			line:   "2024-02-20T07:10:42Z marklap fed the monkey 2 bananas",
+			want:   time.Date(2024, 2, 20, 7, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "rfc3339Offset",
+			line:   "2024-02-20T07:10:42.250+02:00 marklap fed the monkey 2 bananas",
+			want:   time.Date(2024, 2, 20, 5, 10, 42, 250000000, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "rfc3339SpaceNoZone",
+			line:   "level=info ts=2024-02-20 07:10:42,123 msg=fed",
+			want:   time.Date(2024, 2, 20, 7, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "rfc3339NoZoneLocal",
+			line:   "level=info ts=2024-02-20 07:10:42,123 msg=fed",
+			local:  eastOfUTC,
+			want:   time.Date(2024, 2, 20, 5, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "rfc3339ZoneLocal",
+			line:   "2024-02-20T07:10:42Z marklap fed the monkey 2 bananas",
+			local:  eastOfUTC,
+			want:   time.Date(2024, 2, 20, 7, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "rfc3339OffsetNoColon",
+			line:   `{"time":"2024-02-20T07:10:42-0700","msg":"fed"}`,
+			want:   time.Date(2024, 2, 20, 14, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "syslog",
+			line:   "Feb  3 07:10:42 zoo monkey[42]: fed 2 bananas",
+			want:   time.Date(2024, 2, 3, 7, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "syslogLastYear",
+			line:   "<34>Dec 31 23:59:59 zoo monkey[42]: fed 2 bananas",
+			want:   time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "syslogLocal",
+			line:   "Feb  3 07:10:42 zoo monkey[42]: fed 2 bananas",
+			local:  eastOfUTC,
+			want:   time.Date(2024, 2, 3, 5, 10, 42, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "commonLogFormatLocal",
+			line:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			local:  eastOfUTC,
+			want:   time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "commonLogFormat",
+			line:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			want:   time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+			wantOk: true,
+		}, {
+			desc:   "none",
+			line:   "There are 2 hard problems in computer science:",
+			wantOk: false,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			time.Local = time.UTC
+			if tC.local != nil {
+				time.Local = tC.local
+			}
+			got, ok := ParseTimestamp(tC.line)
+			if tC.wantOk != ok {
+				t.Fatalf("unexpected parse result - want: %t, got: %t", tC.wantOk, ok)
+			}
+			if !tC.want.Equal(got) {
+				t.Errorf("unexpected timestamp - want: %s, got: %s", tC.want, got)
+			}
+		})
+	}
+}